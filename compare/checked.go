@@ -0,0 +1,66 @@
+package compare
+
+import "fmt"
+
+// checked returns the parser as a CheckedParser, the parsers which do not
+// implement it are adapted by uncheckedParser.
+func checked(parser Parser) CheckedParser {
+	if p, ok := parser.(CheckedParser); ok {
+		return p
+	}
+	return uncheckedParser{parser: &parser}
+}
+
+// uncheckedParser adapts a Parser to CheckedParser. The nil values and the
+// ones whose Exists method reports false are taken as missing paths by
+// GetChecked. Delete removes the path from the decoded json and parses the
+// result again, the Parser is kept behind a pointer so the copies share it.
+type uncheckedParser struct {
+	parser *Parser
+}
+
+func (p uncheckedParser) Parse(obj interface{}) Parser {
+	return (*p.parser).Parse(obj)
+}
+
+func (p uncheckedParser) ParseChecked(obj interface{}) (CheckedParser, error) {
+	return checked((*p.parser).Parse(obj)), nil
+}
+
+func (p uncheckedParser) Get(path string) interface{} {
+	return (*p.parser).Get(path)
+}
+
+func (p uncheckedParser) GetChecked(path string) (interface{}, error) {
+	val := (*p.parser).Get(path)
+	if exists, ok := val.(interface{ Exists() bool }); val == nil || ok && !exists.Exists() {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPath, path)
+	}
+	return val, nil
+}
+
+func (p uncheckedParser) Set(path string, value interface{}) {
+	(*p.parser).Set(path, value)
+}
+
+func (p uncheckedParser) SetChecked(path string, value interface{}) error {
+	(*p.parser).Set(path, value)
+	return nil
+}
+
+func (p uncheckedParser) Delete(path string) error {
+	if path == "" {
+		return fmt.Errorf("%w: path is empty", ErrInvalidPath)
+	}
+
+	var tree interface{}
+	if err := decode([]byte((*p.parser).Json()), &tree); err != nil {
+		return fmt.Errorf("%w: %v", ErrMarshal, err)
+	}
+	*p.parser = (*p.parser).Parse(deletePath(tree, keys(path)))
+	return nil
+}
+
+func (p uncheckedParser) Json() string {
+	return (*p.parser).Json()
+}
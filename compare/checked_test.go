@@ -0,0 +1,61 @@
+package compare
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// gjsonParser is a Parser without the checked methods.
+type gjsonParser struct {
+	raw *string
+}
+
+func (p gjsonParser) Parse(obj interface{}) Parser {
+	bytes, _ := json.Marshal(obj)
+	raw := string(bytes)
+	return gjsonParser{raw: &raw}
+}
+
+func (p gjsonParser) Get(path string) interface{} {
+	return gjson.Get(*p.raw, path)
+}
+
+func (p gjsonParser) Set(path string, val interface{}) {
+	if result, ok := val.(gjson.Result); ok {
+		*p.raw, _ = sjson.SetRaw(*p.raw, path, result.Raw)
+		return
+	}
+	*p.raw, _ = sjson.Set(*p.raw, path, val)
+}
+
+func (p gjsonParser) Json() string {
+	return *p.raw
+}
+
+func TestUncheckedParser(t *testing.T) {
+	p, err := checked(gjsonParser{}).ParseChecked(map[string]interface{}{"a": 1, "b": []int{1, 2}})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	_, err = p.GetChecked("missing")
+	assert.ErrorIs(t, err, ErrInvalidPath)
+	assert.NoError(t, p.Delete("b.0"))
+	assert.JSONEq(t, `{"a": 1, "b": [2]}`, p.Json())
+
+	comparator := NewComparator(gjsonParser{}, ExcludeField("updated"), FiledPathMap("name", "nick"))
+	report, err := comparator.Diff(
+		map[string]interface{}{"name": "a", "updated": 1},
+		map[string]interface{}{"name": "a", "nick": "a", "updated": 2},
+	)
+	if assert.NoError(t, err) {
+		assert.True(t, report.Equal(), report.String())
+	}
+
+	_, ok := checked(DefaultParser{}).(DefaultParser)
+	assert.True(t, ok)
+}
@@ -2,7 +2,11 @@ package compare
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"reflect"
+	"sort"
+	"sync"
 
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
@@ -11,6 +15,19 @@ import (
 // None Value of empty
 type None struct{}
 
+var (
+	// ErrMarshal is returned when an object can not be converted from or to
+	// json.
+	ErrMarshal = errors.New("marshal failed")
+	// ErrInvalidPath is returned when a path is malformed or does not exist.
+	ErrInvalidPath = errors.New("invalid path")
+	// ErrTypeMismatch is returned when the source can not be converted to the
+	// type of the target.
+	ErrTypeMismatch = errors.New("type mismatch")
+	// ErrNotParsed is returned when the zero value of a parser is changed.
+	ErrNotParsed = errors.New("parser is not parsed")
+)
+
 type Parser interface {
 	// Parse covert object to Parser
 	Parse(interface{}) Parser
@@ -20,86 +37,250 @@ type Parser interface {
 	Json() string
 }
 
+// CheckedParser is a Parser which reports its errors, the comparator uses
+// these methods if the parser implements them, otherwise a Parser is adapted
+// and its failures are not able to be told apart.
+type CheckedParser interface {
+	Parser
+	ParseChecked(interface{}) (CheckedParser, error)
+	// GetChecked returns ErrInvalidPath if the path does not exist.
+	GetChecked(path string) (interface{}, error)
+	SetChecked(path string, val interface{}) error
+	Delete(path string) error
+}
+
 type Comparator struct {
-	parser Parser
+	parser CheckedParser
 
-	include map[string]None
-	exclude map[string]None
-	pathMap map[string]string
+	include   map[string]None
+	exclude   map[string]None
+	pathMap   map[string]string
+	tolerance map[string]tolerance
+	unordered map[string]None
+	arrayKey  map[string]string
+	custom    map[string]func(a, b interface{}) bool
+	// tagged caches the comparators with the rules of the compare tags by
+	// the types of source and target
+	tagged *sync.Map
+	// tags holds the rules of the compare tags except the excluded fields,
+	// which apply when no option matches
+	tags *Comparator
+	// shape compares the json trees without the conversion to the target type
+	shape bool
+	// patterns are compiled from the paths of the options
+	patterns map[string]pattern
+	err      error
+}
+
+// tolerance is the allowed difference of the numbers.
+type tolerance struct {
+	abs, rel float64
 }
 
 type Option func(*Comparator)
 
+// IncludeField compares only the paths which match the pattern of field.
 func IncludeField(field string) Option {
 	return func(c *Comparator) {
 		c.include[field] = None{}
+		c.compile(field)
 	}
 }
 
+// ExcludeField ignores the paths which match the pattern of field.
 func ExcludeField(field string) Option {
 	return func(c *Comparator) {
 		c.exclude[field] = None{}
+		c.compile(field)
 	}
 }
 
+// FiledPathMap copies the value at the source path of the source object to
+// the target path before the comparison, the source path is kept.
 func FiledPathMap(source, target string) Option {
 	return func(c *Comparator) {
 		c.pathMap[source] = target
 	}
 }
 
+// DefaultParser parses the object by its json, and keeps the parsed one
+// behind a pointer so the copies share the changes of Set and Delete. The
+// zero value is an empty document, which is not able to be changed.
 type DefaultParser struct {
-	temp gjson.Result
+	temp *gjson.Result
+}
+
+// doc returns the parsed json, the zero value has an empty one.
+func (p DefaultParser) doc() gjson.Result {
+	if p.temp == nil {
+		return gjson.Result{}
+	}
+	return *p.temp
 }
 
 func (p DefaultParser) Parse(obj interface{}) Parser {
-	bytes, _ := json.Marshal(obj)
+	parsed, err := p.ParseChecked(obj)
+	if err != nil {
+		return DefaultParser{}
+	}
+	return parsed
+}
+
+func (p DefaultParser) ParseChecked(obj interface{}) (CheckedParser, error) {
+	bytes, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMarshal, err)
+	}
 	temp := gjson.ParseBytes(bytes)
-	return DefaultParser{temp: temp}
+	return DefaultParser{temp: &temp}, nil
 }
 
+// Get returns the gjson.Result at path, which does not exist if the path is missing.
 func (p DefaultParser) Get(path string) interface{} {
-	return p.temp.Get(path)
+	return p.doc().Get(path)
+}
+
+func (p DefaultParser) GetChecked(path string) (interface{}, error) {
+	val := p.doc().Get(path)
+	if !val.Exists() {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPath, path)
+	}
+	return val, nil
 }
 
 var sjsonOpt = &sjson.Options{ReplaceInPlace: true}
 
 func (p DefaultParser) Set(path string, value interface{}) {
-	// set value if value is not gjson.Result
-	if val, ok := value.(gjson.Result); !ok {
-		temp, _ := sjson.SetOptions(p.temp.Raw, path, val, sjsonOpt)
-		p.temp = gjson.Parse(temp)
-		return
+	_ = p.SetChecked(path, value)
+}
+
+func (p DefaultParser) SetChecked(path string, value interface{}) error {
+	if p.temp == nil {
+		return ErrNotParsed
 	}
-	val, ok := value.(gjson.Result)
-	paths := val.Paths(p.temp.Raw)
-
-	// is a complex path
-	if ok && val.IsArray() && len(paths) != 0 {
-		// set value in loop
-		for _, vPath := range val.Paths(p.temp.Raw) {
-			raw, item := p.temp.Raw, p.temp.Get(vPath)
-			// ignore error
-			temp, _ := sjson.SetOptions(raw, vPath, item, sjsonOpt)
-			p.temp = gjson.Parse(temp)
-		}
+
+	var (
+		temp string
+		err  error
+	)
+	if val, ok := value.(gjson.Result); ok {
+		temp, err = sjson.SetRawOptions(p.temp.Raw, path, val.Raw, sjsonOpt)
+	} else {
+		temp, err = sjson.SetOptions(p.temp.Raw, path, value, sjsonOpt)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidPath, path, err)
 	}
+	*p.temp = gjson.Parse(temp)
+	return nil
+}
 
-	// if val is a simple value
-	temp, _ := sjson.SetOptions(p.temp.Raw, path, val.Str, sjsonOpt)
-	p.temp = gjson.Parse(temp)
+func (p DefaultParser) Delete(path string) error {
+	if p.temp == nil {
+		// nothing to delete in the empty document
+		return nil
+	}
+	temp, err := sjson.Delete(p.temp.Raw, path)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidPath, path, err)
+	}
+	*p.temp = gjson.Parse(temp)
+	return nil
 }
 
 func (p DefaultParser) Json() string {
-	return p.temp.Raw
+	return p.doc().Raw
+}
+
+// FloatTolerance allows the numbers at path to differ by abs, the empty path
+// applies to all the numbers without their own tolerance.
+func FloatTolerance(path string, abs float64) Option {
+	return func(c *Comparator) {
+		tol := c.tolerance[path]
+		tol.abs = abs
+		c.tolerance[path] = tol
+		c.compile(path)
+	}
+}
+
+// RelativeTolerance allows the numbers at path to differ by rel times the
+// larger absolute value of them, the empty path applies the same as
+// FloatTolerance.
+func RelativeTolerance(path string, rel float64) Option {
+	return func(c *Comparator) {
+		tol := c.tolerance[path]
+		tol.rel = rel
+		c.tolerance[path] = tol
+		c.compile(path)
+	}
+}
+
+// UnorderedArray compares the array at path as a multiset, the items which
+// have no equal one on the other side are reported as removed or added.
+func UnorderedArray(path string) Option {
+	return func(c *Comparator) {
+		c.unordered[path] = None{}
+		c.compile(path)
+	}
+}
+
+// ArrayKey matches the objects of the array at path by their key field, and
+// compares the matched ones field by field. The objects of a key are matched
+// in order, the ones without the key are never matched.
+func ArrayKey(path, key string) Option {
+	return func(c *Comparator) {
+		c.arrayKey[path] = key
+		c.compile(path)
+	}
+}
+
+// NormalizedShape compares the json trees of source and target as they are,
+// instead of converting both of them to the type of target. So the objects of
+// different types are compared symmetrically, and the fields which exist on
+// only one side are reported, see Report.OnlySource and Report.OnlyTarget.
+func NormalizedShape() Option {
+	return func(c *Comparator) {
+		c.shape = true
+	}
+}
+
+// CompareWith compares the values at path by equal instead of the default
+// rules, the values are decoded json, e.g. map[string]interface{} and
+// json.Number. equal is called only when both of the values exist.
+func CompareWith(path string, equal func(a, b interface{}) bool) Option {
+	return func(c *Comparator) {
+		c.custom[path] = equal
+		c.compile(path)
+	}
+}
+
+// compile compiles the path of an option, the error is returned by Diff.
+func (c *Comparator) compile(path string) {
+	if _, ok := c.patterns[path]; ok || path == "" {
+		return
+	}
+	p, err := compilePattern(path)
+	if err != nil {
+		if c.err == nil {
+			c.err = err
+		}
+		return
+	}
+	c.patterns[path] = p
 }
 
 func NewComparator(parser Parser, opts ...Option) Comparator {
 	temp := Comparator{
-		parser:  parser,
-		include: make(map[string]None),
-		exclude: make(map[string]None),
-		pathMap: make(map[string]string),
+		parser:    checked(parser),
+		include:   make(map[string]None),
+		exclude:   make(map[string]None),
+		pathMap:   make(map[string]string),
+		tolerance: make(map[string]tolerance),
+		unordered: make(map[string]None),
+		arrayKey:  make(map[string]string),
+		custom:    make(map[string]func(a, b interface{}) bool),
+		patterns:  make(map[string]pattern),
+		tagged:    &sync.Map{},
 	}
 	for _, opt := range opts {
 		if opt == nil {
@@ -110,49 +291,214 @@ func NewComparator(parser Parser, opts ...Option) Comparator {
 	return temp
 }
 
+// Equal reports whether source and target are equal, both of them are
+// converted to the type of target before the comparison unless
+// NormalizedShape is set. It is false if they can not be compared, Compare
+// returns the error in that case.
 func (c Comparator) Equal(source, target interface{}) bool {
-	s, t := c.parser.Parse(source), c.parser.Parse(target)
+	equal, err := c.Compare(source, target)
+	return err == nil && equal
+}
+
+// Compare is the same as Equal, but returns the error which stops the
+// comparison.
+func (c Comparator) Compare(source, target interface{}) (bool, error) {
+	report, err := c.Diff(source, target)
+	if err != nil {
+		return false, err
+	}
+	return report.Equal(), nil
+}
+
+// Diff compares source and target the same as Equal, and reports every path
+// which differs.
+func (c Comparator) Diff(source, target interface{}) (Report, error) {
+	c, err := c.withTags(source, target)
+	if err != nil {
+		return Report{}, err
+	}
+	sTree, tTree, err := c.trees(source, target)
+	if err != nil {
+		return Report{}, err
+	}
+
+	var report Report
+	c.diff(&report, nil, nil, sTree, tTree)
+	return report, nil
+}
+
+// trees returns the json trees of source and target to compare.
+func (c Comparator) trees(source, target interface{}) (interface{}, interface{}, error) {
+	if c.err != nil {
+		return nil, nil, c.err
+	}
+
+	var tType reflect.Type
+	if !c.shape {
+		tType = reflect.TypeOf(target)
+	}
+	sTree, err := c.tree(source, tType, true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("source: %w", err)
+	}
+	tTree, err := c.tree(target, tType, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("target: %w", err)
+	}
+	return sTree, tTree, nil
+}
+
+// tree parses obj with the options applied, and returns its json tree after
+// it is converted to the type.
+func (c Comparator) tree(obj interface{}, typ reflect.Type, source bool) (interface{}, error) {
+	p, err := c.parser.ParseChecked(obj)
+	if err != nil {
+		return nil, err
+	}
+	mapped := source && len(c.pathMap) != 0
+	if mapped {
+		if p, err = c.mapField(p); err != nil {
+			return nil, err
+		}
+	}
+	if p, err = c.dumpFields(p); err != nil {
+		return nil, err
+	}
 
-	tType := reflect.TypeOf(target)
-	if len(c.pathMap) != 0 {
-		s = c.mapField(s)
+	// the tree of the type itself needs no conversion, unless the options
+	// changed it, the conversion drops the unknown fields and fills the
+	// missing ones
+	changed := mapped || len(c.include) != 0 || len(c.exclude) != 0
+	if t, ok := p.(treeParser); ok && (typ == nil || !changed && reflect.TypeOf(obj) == typ) {
+		return t.Tree(), nil
 	}
+	return convert(p.Json(), typ)
+}
 
-	sStr, sTemp := c.dumpFields(s).Json(), reflect.New(tType).Interface()
-	tStr, tTemp := c.dumpFields(t).Json(), reflect.New(tType).Interface()
+// treeParser is a CheckedParser which keeps the json tree, which is taken
+// instead of decoding the json.
+type treeParser interface {
+	CheckedParser
+	Tree() interface{}
+}
 
-	if err := json.Unmarshal([]byte(sStr), sTemp); err != nil {
-		return false
+// treeOf returns the json tree of the parsed object.
+func treeOf(p CheckedParser) (interface{}, error) {
+	if t, ok := p.(treeParser); ok {
+		return t.Tree(), nil
 	}
-	if err := json.Unmarshal([]byte(tStr), tTemp); err != nil {
-		return false
+
+	var tree interface{}
+	if err := decode([]byte(p.Json()), &tree); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMarshal, err)
 	}
+	return tree, nil
+}
 
-	return reflect.DeepEqual(sTemp, tTemp)
+// convert decodes str as the type, and returns its json tree.
+func convert(str string, typ reflect.Type) (interface{}, error) {
+	if typ != nil {
+		temp := reflect.New(typ).Interface()
+		if err := decode([]byte(str), temp); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				return nil, fmt.Errorf("%w: %v", ErrTypeMismatch, err)
+			}
+			return nil, fmt.Errorf("%w: %v", ErrMarshal, err)
+		}
+		bytes, err := json.Marshal(temp)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMarshal, err)
+		}
+		str = string(bytes)
+	}
+
+	var tree interface{}
+	if err := decode([]byte(str), &tree); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMarshal, err)
+	}
+	return tree, nil
 }
 
-func (c Comparator) dumpFields(source Parser) Parser {
+// dumpFields keeps the paths which match the included patterns, or removes
+// the ones which match the excluded patterns.
+func (c Comparator) dumpFields(source CheckedParser) (CheckedParser, error) {
+	if len(c.include) == 0 && len(c.exclude) == 0 {
+		return source, nil
+	}
+
+	tree, err := treeOf(source)
+	if err != nil {
+		return nil, err
+	}
+
 	if len(c.include) > 0 {
-		empty := c.parser.Parse(None{})
-		for path := range c.include {
-			empty.Set(path, source.Get(path))
+		empty, err := c.parser.ParseChecked(None{})
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range c.expand(c.include, tree) {
+			val, err := source.GetChecked(path)
+			if err != nil {
+				return nil, err
+			}
+			if err := empty.SetChecked(path, val); err != nil {
+				return nil, err
+			}
+		}
+		return empty, nil
+	}
+
+	// remove the children before their parents, and the later items of an
+	// array before the former ones
+	paths := c.expand(c.exclude, tree)
+	for i := len(paths) - 1; i >= 0; i-- {
+		if err := source.Delete(paths[i]); err != nil {
+			return nil, err
 		}
-		return empty
 	}
+	return source, nil
+}
 
-	if len(c.exclude) == 0 {
-		return source
+// expand returns the paths of the tree which match the patterns, in the order
+// of lessPath.
+func (c Comparator) expand(patterns map[string]None, tree interface{}) []string {
+	var (
+		paths [][]string
+		seen  = make(map[string]None)
+	)
+	for key := range patterns {
+		for _, path := range c.patterns[key].expand(tree) {
+			joined := joinPath(path)
+			if _, ok := seen[joined]; !ok {
+				seen[joined] = None{}
+				paths = append(paths, path)
+			}
+		}
 	}
+	sort.Slice(paths, func(i, j int) bool {
+		return lessPath(paths[i], paths[j])
+	})
 
-	for path := range c.exclude {
-		source.Set(path, None{})
+	joined := make([]string, len(paths))
+	for i, path := range paths {
+		joined[i] = joinPath(path)
 	}
-	return source
+	return joined
 }
 
-func (c Comparator) mapField(source Parser) Parser {
+func (c Comparator) mapField(source CheckedParser) (CheckedParser, error) {
 	for s, t := range c.pathMap {
-		source.Set(t, source.Get(s))
+		val, err := source.GetChecked(s)
+		if errors.Is(err, ErrInvalidPath) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := source.SetChecked(t, val); err != nil {
+			return nil, err
+		}
 	}
-	return source
+	return source, nil
 }
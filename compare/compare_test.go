@@ -26,3 +26,51 @@ var includeTable = []struct {
 }{
 	{simpleMap, simpleMap, []Option{IncludeField("key")}, true},
 }
+
+func TestDefaultParser(t *testing.T) {
+	p, err := DefaultParser{}.ParseChecked(simpleMap)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.NoError(t, p.SetChecked("nested.key", 1))
+	assert.Equal(t, `{"key":"value","nested":{"key":1}}`, p.Json())
+
+	val, err := p.GetChecked("key")
+	if assert.NoError(t, err) {
+		assert.NoError(t, p.SetChecked("copy", val))
+	}
+	assert.NoError(t, p.Delete("nested"))
+	assert.Equal(t, `{"key":"value","copy":"value"}`, p.Json())
+
+	_, err = p.GetChecked("missing")
+	assert.ErrorIs(t, err, ErrInvalidPath)
+	assert.ErrorIs(t, p.SetChecked("", 1), ErrInvalidPath)
+
+	_, err = DefaultParser{}.ParseChecked(make(chan int))
+	assert.ErrorIs(t, err, ErrMarshal)
+}
+
+func TestDefaultParserZero(t *testing.T) {
+	var p DefaultParser
+	_, err := p.GetChecked("key")
+	assert.ErrorIs(t, err, ErrInvalidPath)
+	assert.ErrorIs(t, p.SetChecked("key", 1), ErrNotParsed)
+	assert.NoError(t, p.Delete("key"))
+	assert.Equal(t, "", p.Json())
+}
+
+func TestCompareError(t *testing.T) {
+	comparator := NewComparator(DefaultParser{})
+
+	_, err := comparator.Compare(map[string]interface{}{"age": "1"}, user{})
+	assert.ErrorIs(t, err, ErrTypeMismatch)
+	assert.False(t, comparator.Equal(map[string]interface{}{"age": "1"}, user{}))
+
+	_, err = comparator.Compare(func() {}, user{})
+	assert.ErrorIs(t, err, ErrMarshal)
+
+	equal, err := comparator.Compare(user{Name: "a"}, user{Name: "a"})
+	assert.NoError(t, err)
+	assert.True(t, equal)
+}
@@ -0,0 +1,374 @@
+package compare
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kind is the kind of a difference.
+type Kind string
+
+const (
+	// Added path exists in target only.
+	Added Kind = "added"
+	// Removed path exists in source only.
+	Removed Kind = "removed"
+	// Changed path has different values of the same json type.
+	Changed Kind = "changed"
+	// TypeMismatch path has values of different json types.
+	TypeMismatch Kind = "type-mismatch"
+)
+
+// Difference is a path which differs, Source and Target are its json values,
+// the missing one is nil.
+type Difference struct {
+	Path   string
+	Kind   Kind
+	Source interface{}
+	Target interface{}
+	// Custom reports whether the values are compared by CompareWith.
+	Custom bool
+}
+
+func (d Difference) String() string {
+	switch d.Kind {
+	case Added:
+		return fmt.Sprintf("%s %s: %v", d.Kind, d.Path, d.Target)
+	case Removed:
+		return fmt.Sprintf("%s %s: %v", d.Kind, d.Path, d.Source)
+	case Changed:
+		if d.Custom {
+			return fmt.Sprintf("%s %s: %v != %v (custom)", d.Kind, d.Path, d.Source, d.Target)
+		}
+		fallthrough
+	default:
+		return fmt.Sprintf("%s %s: %v != %v", d.Kind, d.Path, d.Source, d.Target)
+	}
+}
+
+// Report lists the differences in the order of their path, the keys of an
+// object are sorted.
+type Report struct {
+	Differences []Difference
+	// fields are the paths of the object members which exist on one side,
+	// by the kind of their difference
+	fields map[Kind][]string
+}
+
+// Equal reports whether there is no difference.
+func (r Report) Equal() bool {
+	return len(r.Differences) == 0
+}
+
+// OnlySource returns the paths of the object members which exist in source
+// only. The items of the arrays which have no match in target are reported
+// as Removed differences, but they are not fields and not included.
+func (r Report) OnlySource() []string {
+	return r.fields[Removed]
+}
+
+// OnlyTarget returns the paths of the object members which exist in target
+// only, the same as OnlySource.
+func (r Report) OnlyTarget() []string {
+	return r.fields[Added]
+}
+
+func (r Report) String() string {
+	lines := make([]string, len(r.Differences))
+	for i, d := range r.Differences {
+		lines[i] = d.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r *Report) add(path []string, kind Kind, source, target interface{}) {
+	r.Differences = append(r.Differences, Difference{
+		Path:   joinPath(path),
+		Kind:   kind,
+		Source: source,
+		Target: target,
+	})
+}
+
+// addField adds the difference of an object member which exists on one side.
+func (r *Report) addField(path []string, kind Kind, source, target interface{}) {
+	r.add(path, kind, source, target)
+	if r.fields == nil {
+		r.fields = make(map[Kind][]string)
+	}
+	r.fields[kind] = append(r.fields[kind], joinPath(path))
+}
+
+// diff compares the json trees at path, indexes reports whether each key of
+// the path is an index of an array.
+func (c Comparator) diff(r *Report, path []string, indexes []bool, source, target interface{}) {
+	if equal, ok := matchRule(c, c.custom, path, indexes); ok {
+		if !equal(source, target) {
+			r.Differences = append(r.Differences, Difference{
+				Path:   joinPath(path),
+				Kind:   Changed,
+				Source: source,
+				Target: target,
+				Custom: true,
+			})
+		}
+		return
+	}
+
+	if jsonType(source) != jsonType(target) {
+		r.add(path, TypeMismatch, source, target)
+		return
+	}
+
+	switch s := source.(type) {
+	case map[string]interface{}:
+		t := target.(map[string]interface{})
+		for _, key := range mergeKeys(s, t) {
+			sVal, sOk := s[key]
+			tVal, tOk := t[key]
+			switch {
+			case !tOk:
+				r.addField(append(path, key), Removed, sVal, nil)
+			case !sOk:
+				r.addField(append(path, key), Added, nil, tVal)
+			default:
+				c.diff(r, append(path, key), append(indexes, false), sVal, tVal)
+			}
+		}
+	case []interface{}:
+		t := target.([]interface{})
+		key, keyed := matchRule(c, c.arrayKey, path, indexes)
+		_, unordered := matchRule(c, c.unordered, path, indexes)
+		if !keyed && !unordered && c.tags != nil {
+			key, keyed = matchRule(*c.tags, c.tags.arrayKey, path, indexes)
+			_, unordered = matchRule(*c.tags, c.tags.unordered, path, indexes)
+		}
+		if keyed {
+			c.diffKeyed(r, path, indexes, key, s, t)
+			return
+		}
+		if unordered {
+			c.diffUnordered(r, path, indexes, s, t)
+			return
+		}
+		for i := 0; i < len(s) || i < len(t); i++ {
+			index := append(path, strconv.Itoa(i))
+			switch {
+			case i >= len(t):
+				r.add(index, Removed, s[i], nil)
+			case i >= len(s):
+				r.add(index, Added, nil, t[i])
+			default:
+				c.diff(r, index, append(indexes, true), s[i], t[i])
+			}
+		}
+	case json.Number:
+		if !c.equalNumber(path, indexes, s, target.(json.Number)) {
+			r.add(path, Changed, source, target)
+		}
+	default:
+		if source != target {
+			r.add(path, Changed, source, target)
+		}
+	}
+}
+
+// diffKeyed compares the items of the arrays which have the same key, the
+// paths of the items are their index in source, and in target for the added
+// ones.
+func (c Comparator) diffKeyed(r *Report, path []string, indexes []bool, key string, source, target []interface{}) {
+	items := make(map[string][]int)
+	for i, item := range target {
+		if id, ok := identity(item, key); ok {
+			items[id] = append(items[id], i)
+		}
+	}
+
+	matched := make([]bool, len(target))
+	for i, item := range source {
+		index := append(path, strconv.Itoa(i))
+		id, ok := identity(item, key)
+		if !ok || len(items[id]) == 0 {
+			r.add(index, Removed, item, nil)
+			continue
+		}
+		j := items[id][0]
+		items[id] = items[id][1:]
+		matched[j] = true
+		c.diff(r, index, append(indexes, true), item, target[j])
+	}
+	for j, item := range target {
+		if !matched[j] {
+			r.add(append(path, strconv.Itoa(j)), Added, nil, item)
+		}
+	}
+}
+
+// diffUnordered matches the equal items of the arrays, the paths of the items
+// are the same as diffKeyed.
+func (c Comparator) diffUnordered(r *Report, path []string, indexes []bool, source, target []interface{}) {
+	matched := make([]bool, len(target))
+	for i, item := range source {
+		index := append(path, strconv.Itoa(i))
+		found := false
+		for j := range target {
+			if matched[j] {
+				continue
+			}
+			var temp Report
+			if c.diff(&temp, index, append(indexes, true), item, target[j]); temp.Equal() {
+				matched[j], found = true, true
+				break
+			}
+		}
+		if !found {
+			r.add(index, Removed, item, nil)
+		}
+	}
+	for j, item := range target {
+		if !matched[j] {
+			r.add(append(path, strconv.Itoa(j)), Added, nil, item)
+		}
+	}
+}
+
+// identity returns the identity of the key field of an object item.
+func identity(item interface{}, key string) (string, bool) {
+	object, ok := item.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	switch val := object[key].(type) {
+	case string:
+		return "s" + val, true
+	case json.Number:
+		// the same number in different formats has the same identity
+		if rat, ok := new(big.Rat).SetString(string(val)); ok {
+			return "n" + rat.RatString(), true
+		}
+		return "n" + string(val), true
+	case bool:
+		return fmt.Sprint("b", val), true
+	default:
+		return "", false
+	}
+}
+
+// equalNumber compares the numbers with the tolerance of the path, or the
+// global one if the path has none.
+func (c Comparator) equalNumber(path []string, indexes []bool, s, t json.Number) bool {
+	if equalNumber(s, t) {
+		return true
+	}
+
+	tol, ok := matchRule(c, c.tolerance, path, indexes)
+	if !ok {
+		tol, ok = c.tolerance[""]
+	}
+	if !ok && c.tags != nil {
+		tol, ok = matchRule(*c.tags, c.tags.tolerance, path, indexes)
+	}
+	a, aErr := s.Float64()
+	b, bErr := t.Float64()
+	if !ok || aErr != nil || bErr != nil {
+		return false
+	}
+
+	delta := math.Abs(a - b)
+	return delta <= tol.abs || delta <= tol.rel*math.Max(math.Abs(a), math.Abs(b))
+}
+
+// equalNumber compares the numbers exactly, so the large integers do not lose
+// their precision through float64.
+func equalNumber(s, t json.Number) bool {
+	a, aOk := new(big.Rat).SetString(string(s))
+	b, bOk := new(big.Rat).SetString(string(t))
+	if !aOk || !bOk {
+		return s == t
+	}
+	return a.Cmp(b) == 0
+}
+
+// equalTree compares the json trees exactly.
+func equalTree(source, target interface{}) bool {
+	var report Report
+	Comparator{}.diff(&report, nil, nil, source, target)
+	return report.Equal()
+}
+
+// decode decodes the json with the numbers kept as json.Number.
+func decode(data []byte, val interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(val); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("invalid character after top-level value")
+	}
+	return nil
+}
+
+// jsonType returns the json type of a decoded value.
+func jsonType(val interface{}) string {
+	switch val.(type) {
+	case nil:
+		return "null"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		return "unknown"
+	}
+}
+
+// mergeKeys returns the sorted keys of both objects.
+func mergeKeys(s, t map[string]interface{}) []string {
+	keys := make([]string, 0, len(s)+len(t))
+	for key := range s {
+		keys = append(keys, key)
+	}
+	for key := range t {
+		if _, ok := s[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// joinPath joins the keys into a gjson path, the special characters of the
+// keys are escaped.
+func joinPath(path []string) string {
+	keys := make([]string, len(path))
+	for i, key := range path {
+		keys[i] = escapeKey(key)
+	}
+	return strings.Join(keys, ".")
+}
+
+func escapeKey(key string) string {
+	var b strings.Builder
+	for _, r := range key {
+		if strings.ContainsRune(`\.*?|#@!`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,252 @@
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type user struct {
+	Name  string   `json:"name"`
+	Age   int      `json:"age"`
+	Tags  []string `json:"tags"`
+	Email string   `json:"email,omitempty"`
+}
+
+func TestDiff(t *testing.T) {
+	for _, item := range diffTable {
+		comparator := NewComparator(DefaultParser{}, item.option...)
+		report, err := comparator.Diff(item.source, item.target)
+		if assert.NoError(t, err) {
+			assert.Equal(t, item.except, report.Differences, item.name)
+			assert.Equal(t, len(item.except) == 0, comparator.Equal(item.source, item.target), item.name)
+		}
+	}
+}
+
+var diffTable = []struct {
+	name   string
+	source interface{}
+	target interface{}
+	option []Option
+	except []Difference
+}{
+	{
+		name:   "equal",
+		source: user{Name: "a", Tags: []string{"x"}},
+		target: user{Name: "a", Tags: []string{"x"}},
+	},
+	{
+		name:   "changed",
+		source: user{Name: "a", Age: 1},
+		target: user{Name: "b", Age: 1},
+		except: []Difference{{Path: "name", Kind: Changed, Source: "a", Target: "b"}},
+	},
+	{
+		name:   "array",
+		source: user{Tags: []string{"x", "y"}},
+		target: user{Tags: []string{"x"}},
+		except: []Difference{{Path: "tags.1", Kind: Removed, Source: "y"}},
+	},
+	{
+		name:   "added",
+		source: user{Name: "a"},
+		target: user{Name: "a", Email: "a@b.c"},
+		except: []Difference{{Path: "email", Kind: Added, Target: "a@b.c"}},
+	},
+	{
+		name:   "type mismatch",
+		source: map[string]interface{}{"id": "1", "a.b": 1},
+		target: map[string]interface{}{"id": 1, "a.b": 1},
+		except: []Difference{{Path: "id", Kind: TypeMismatch, Source: "1", Target: json.Number("1")}},
+	},
+	{
+		name:   "escaped path",
+		source: map[string]interface{}{"a.b": 1},
+		target: map[string]interface{}{"a.b": 2},
+		except: []Difference{{Path: `a\.b`, Kind: Changed, Source: json.Number("1"), Target: json.Number("2")}},
+	},
+	{
+		name:   "include",
+		source: user{Name: "a", Age: 1},
+		target: user{Name: "b", Age: 2},
+		option: []Option{IncludeField("age")},
+		except: []Difference{{Path: "age", Kind: Changed, Source: json.Number("1"), Target: json.Number("2")}},
+	},
+	{
+		name:   "exclude",
+		source: map[string]interface{}{"name": "a", "updated": 1},
+		target: map[string]interface{}{"name": "a", "updated": 2},
+		option: []Option{ExcludeField("updated")},
+	},
+	{
+		name:   "path map",
+		source: map[string]interface{}{"user": map[string]interface{}{"name": "a"}},
+		target: map[string]interface{}{"name": "a"},
+		option: []Option{FiledPathMap("user.name", "name"), ExcludeField("user")},
+	},
+	{
+		name:   "path map copies",
+		source: map[string]interface{}{"name": "a"},
+		target: map[string]interface{}{"name": "a", "nick": "a"},
+		option: []Option{FiledPathMap("name", "nick")},
+	},
+	{
+		name:   "large integer",
+		source: map[string]interface{}{"id": int64(9007199254740993)},
+		target: map[string]interface{}{"id": int64(9007199254740992)},
+		except: []Difference{{Path: "id", Kind: Changed, Source: json.Number("9007199254740993"), Target: json.Number("9007199254740992")}},
+	},
+	{
+		name:   "number format",
+		source: json.RawMessage(`{"price": 1.50}`),
+		target: json.RawMessage(`{"price": 1.5e0}`),
+	},
+	{
+		name:   "float",
+		source: map[string]interface{}{"price": 0.30000000000000004},
+		target: map[string]interface{}{"price": 0.3},
+		except: []Difference{{Path: "price", Kind: Changed, Source: json.Number("0.30000000000000004"), Target: json.Number("0.3")}},
+	},
+	{
+		name:   "float tolerance",
+		source: map[string]interface{}{"price": 0.30000000000000004, "count": 1.0},
+		target: map[string]interface{}{"price": 0.3, "count": 1.1},
+		option: []Option{FloatTolerance("price", 1e-6)},
+		except: []Difference{{Path: "count", Kind: Changed, Source: json.Number("1"), Target: json.Number("1.1")}},
+	},
+	{
+		name:   "global tolerance",
+		source: map[string]interface{}{"price": 0.30000000000000004, "count": 1.0},
+		target: map[string]interface{}{"price": 0.3, "count": 1.1},
+		option: []Option{FloatTolerance("", 1e-6), FloatTolerance("count", 0.2)},
+	},
+	{
+		name:   "relative tolerance",
+		source: map[string]interface{}{"big": 1000000.0, "small": 1.0},
+		target: map[string]interface{}{"big": 1000001.0, "small": 2.0},
+		option: []Option{RelativeTolerance("", 1e-5)},
+		except: []Difference{{Path: "small", Kind: Changed, Source: json.Number("1"), Target: json.Number("2")}},
+	},
+	{
+		name:   "unordered",
+		source: json.RawMessage(`{"tags": [1, 2, 2, 3]}`),
+		target: json.RawMessage(`{"tags": [3, 2, 1, 2]}`),
+		option: []Option{UnorderedArray("tags")},
+	},
+	{
+		name:   "unordered difference",
+		source: json.RawMessage(`{"tags": [1, 2, 2]}`),
+		target: json.RawMessage(`{"tags": [2, 3, 2]}`),
+		option: []Option{UnorderedArray("tags")},
+		except: []Difference{
+			{Path: "tags.0", Kind: Removed, Source: json.Number("1")},
+			{Path: "tags.1", Kind: Added, Target: json.Number("3")},
+		},
+	},
+	{
+		name:   "array key",
+		source: json.RawMessage(`{"items": [{"id": 1, "price": 1}, {"id": "2", "price": 2}, {"id": 4}]}`),
+		target: json.RawMessage(`{"items": [{"id": "2", "price": 3}, {"id": 1.0, "price": 1}, {"id": 3}]}`),
+		option: []Option{ArrayKey("items", "id")},
+		except: []Difference{
+			{Path: "items.1.price", Kind: Changed, Source: json.Number("2"), Target: json.Number("3")},
+			{Path: "items.2", Kind: Removed, Source: map[string]interface{}{"id": json.Number("4")}},
+			{Path: "items.2", Kind: Added, Target: map[string]interface{}{"id": json.Number("3")}},
+		},
+	},
+	{
+		name:   "converted to target type",
+		source: map[string]interface{}{"name": "a", "unknown": 1},
+		target: user{Name: "a"},
+	},
+}
+
+func TestReportString(t *testing.T) {
+	report := Report{Differences: []Difference{
+		{Path: "name", Kind: Changed, Source: "a", Target: "b"},
+		{Path: "tags.1", Kind: Removed, Source: "y"},
+	}}
+	assert.Equal(t, "changed name: a != b\nremoved tags.1: y", report.String())
+}
+
+func TestCompareWith(t *testing.T) {
+	withoutQuery := func(a, b interface{}) bool {
+		x, _ := a.(string)
+		y, _ := b.(string)
+		return strings.Split(x, "?")[0] == strings.Split(y, "?")[0]
+	}
+	withinSecond := func(a, b interface{}) bool {
+		x, xErr := time.Parse(time.RFC3339Nano, fmt.Sprint(a))
+		y, yErr := time.Parse(time.RFC3339Nano, fmt.Sprint(b))
+		return xErr == nil && yErr == nil && x.Sub(y).Abs() <= time.Second
+	}
+	foldCase := func(a, b interface{}) bool {
+		return strings.EqualFold(fmt.Sprint(a), fmt.Sprint(b))
+	}
+
+	comparator := NewComparator(DefaultParser{},
+		CompareWith("url", withoutQuery),
+		CompareWith("created_at", withinSecond),
+		CompareWith("users.#.email", foldCase),
+	)
+	source := json.RawMessage(`{
+		"url": "https://a.com/x?t=1",
+		"created_at": "2024-01-01T00:00:00.2Z",
+		"users": [{"email": "A@b.com"}, {"email": "c@d.com"}]
+	}`)
+	target := json.RawMessage(`{
+		"url": "https://a.com/x?t=2",
+		"created_at": "2024-01-01T00:00:01Z",
+		"users": [{"email": "a@B.com"}, {"email": "e@d.com"}]
+	}`)
+
+	report, err := comparator.Diff(source, target)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []Difference{
+			{Path: "users.1.email", Kind: Changed, Source: "c@d.com", Target: "e@d.com", Custom: true},
+		}, report.Differences)
+		assert.Equal(t, "changed users.1.email: c@d.com != e@d.com (custom)", report.String())
+	}
+}
+
+func TestNormalizedShape(t *testing.T) {
+	dto := user{Name: "a", Age: 1}
+	doc := map[string]interface{}{"name": "a", "age": 1.0, "tags": nil, "unknown": true}
+
+	// the unknown field is dropped by the conversion to user
+	assert.True(t, NewComparator(DefaultParser{}).Equal(doc, dto))
+	assert.False(t, NewComparator(DefaultParser{}).Equal(dto, doc))
+
+	for _, parser := range []Parser{DefaultParser{}, ReflectParser{}} {
+		comparator := NewComparator(parser, NormalizedShape())
+
+		report, err := comparator.Diff(doc, dto)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{"unknown"}, report.OnlySource())
+			assert.Empty(t, report.OnlyTarget())
+		}
+
+		report, err = comparator.Diff(dto, doc)
+		if assert.NoError(t, err) {
+			assert.Empty(t, report.OnlySource())
+			assert.Equal(t, []string{"unknown"}, report.OnlyTarget())
+		}
+	}
+
+	// the unmatched items of the arrays are not fields
+	comparator := NewComparator(DefaultParser{}, NormalizedShape(), UnorderedArray("tags"))
+	report, err := comparator.Diff(
+		map[string]interface{}{"tags": []string{"a", "b"}, "old": 1},
+		map[string]interface{}{"tags": []string{"c", "a"}, "new": 1},
+	)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"new", "old", "tags.1", "tags.0"}, paths(report))
+		assert.Equal(t, []string{"old"}, report.OnlySource())
+		assert.Equal(t, []string{"new"}, report.OnlyTarget())
+	}
+}
@@ -0,0 +1,397 @@
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Operation is an operation of JSON Patch (RFC 6902), Path and From are json
+// pointers.
+type Operation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// MarshalJSON keeps the value of add, replace and test even if it is empty.
+func (o Operation) MarshalJSON() ([]byte, error) {
+	op := map[string]interface{}{"op": o.Op, "path": o.Path}
+	switch o.Op {
+	case "add", "replace", "test":
+		op["value"] = o.Value
+	case "move", "copy":
+		op["from"] = o.From
+	}
+	return json.Marshal(op)
+}
+
+// Patch is a JSON Patch (RFC 6902).
+type Patch []Operation
+
+// Patch returns the JSON Patch which turns source into target, both of them
+// are converted the same as Equal. The values which are equal by the rules of
+// the comparator, such as the tolerance and the unordered arrays, are left as
+// they are, the other arrays are patched by position.
+func (c Comparator) Patch(source, target interface{}) (Patch, error) {
+	c, err := c.withTags(source, target)
+	if err != nil {
+		return nil, err
+	}
+	sTree, tTree, err := c.trees(source, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var patch Patch
+	c.patch(&patch, nil, nil, sTree, tTree)
+	return patch, nil
+}
+
+// equalAt compares the json trees at path by the rules of the comparator.
+func (c Comparator) equalAt(path []string, indexes []bool, source, target interface{}) bool {
+	var report Report
+	c.diff(&report, path, indexes, source, target)
+	return report.Equal()
+}
+
+func (c Comparator) patch(p *Patch, path []string, indexes []bool, source, target interface{}) {
+	if c.equalAt(path, indexes, source, target) {
+		return
+	}
+	if jsonType(source) != jsonType(target) {
+		*p = append(*p, Operation{Op: "replace", Path: pointer(path), Value: target})
+		return
+	}
+
+	switch s := source.(type) {
+	case map[string]interface{}:
+		t := target.(map[string]interface{})
+		for _, key := range mergeKeys(s, t) {
+			sVal, sOk := s[key]
+			tVal, tOk := t[key]
+			switch {
+			case !tOk:
+				*p = append(*p, Operation{Op: "remove", Path: pointer(append(path, key))})
+			case !sOk:
+				*p = append(*p, Operation{Op: "add", Path: pointer(append(path, key)), Value: tVal})
+			default:
+				c.patch(p, append(path, key), append(indexes, false), sVal, tVal)
+			}
+		}
+	case []interface{}:
+		t := target.([]interface{})
+		for i := 0; i < len(s) && i < len(t); i++ {
+			c.patch(p, append(path, strconv.Itoa(i)), append(indexes, true), s[i], t[i])
+		}
+		// remove from the end, so the indexes of the others are kept
+		for i := len(s) - 1; i >= len(t); i-- {
+			*p = append(*p, Operation{Op: "remove", Path: pointer(append(path, strconv.Itoa(i)))})
+		}
+		for i := len(s); i < len(t); i++ {
+			*p = append(*p, Operation{Op: "add", Path: pointer(append(path, strconv.Itoa(i))), Value: t[i]})
+		}
+	default:
+		*p = append(*p, Operation{Op: "replace", Path: pointer(path), Value: target})
+	}
+}
+
+// MergePatch returns the JSON Merge Patch (RFC 7386) which turns source into
+// target, both of them are converted the same as Equal, and the values which
+// are equal by the rules of the comparator are left out. The null values of
+// target can not be expressed by a merge patch, they are removed.
+func (c Comparator) MergePatch(source, target interface{}) (json.RawMessage, error) {
+	c, err := c.withTags(source, target)
+	if err != nil {
+		return nil, err
+	}
+	sTree, tTree, err := c.trees(source, target)
+	if err != nil {
+		return nil, err
+	}
+
+	bytes, err := json.Marshal(c.mergePatch(nil, nil, sTree, tTree))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMarshal, err)
+	}
+	return bytes, nil
+}
+
+func (c Comparator) mergePatch(path []string, indexes []bool, source, target interface{}) interface{} {
+	s, sOk := source.(map[string]interface{})
+	t, tOk := target.(map[string]interface{})
+	if !sOk || !tOk {
+		return target
+	}
+
+	patch := map[string]interface{}{}
+	for _, key := range mergeKeys(s, t) {
+		sVal, sOk := s[key]
+		tVal, tOk := t[key]
+		switch {
+		case !tOk:
+			patch[key] = nil
+		case !sOk:
+			patch[key] = c.mergePatch(append(path, key), append(indexes, false), nil, tVal)
+		case !c.equalAt(append(path, key), append(indexes, false), sVal, tVal):
+			patch[key] = c.mergePatch(append(path, key), append(indexes, false), sVal, tVal)
+		}
+	}
+	return patch
+}
+
+// Apply applies the JSON Patch to doc through parser, and returns the parsed
+// doc after the patch. The patch stops at the first failed operation.
+func Apply(parser Parser, doc interface{}, patch Patch) (Parser, error) {
+	checker := checked(parser)
+	p, err := checker.ParseChecked(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, op := range patch {
+		if p, err = apply(checker, p, op); err != nil {
+			return nil, fmt.Errorf("operation %d %s %s: %w", i, op.Op, op.Path, err)
+		}
+	}
+	return p, nil
+}
+
+func apply(parser CheckedParser, p CheckedParser, op Operation) (CheckedParser, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case "add":
+		return add(parser, p, path, op.Value)
+	case "remove":
+		return p, remove(p, path)
+	case "replace":
+		if len(path) == 0 {
+			return parser.ParseChecked(op.Value)
+		}
+		if _, err := p.GetChecked(joinPath(path)); err != nil {
+			return nil, err
+		}
+		return p, p.SetChecked(joinPath(path), op.Value)
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if len(from) == 0 {
+			return nil, fmt.Errorf("%w: can not %s the root", ErrInvalidPath, op.Op)
+		}
+		val, err := p.GetChecked(joinPath(from))
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if err := remove(p, from); err != nil {
+				return nil, err
+			}
+		}
+		return add(parser, p, path, val)
+	case "test":
+		val, ok := lookup(p, path)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPath, op.Path)
+		}
+		if !equalTree(val, normalize(op.Value)) {
+			return nil, fmt.Errorf("test failed: %v != %v", val, op.Value)
+		}
+		return p, nil
+	default:
+		return nil, fmt.Errorf("unknown operation %q", op.Op)
+	}
+}
+
+// add sets the value of an object, or inserts the value into an array.
+func add(parser CheckedParser, p CheckedParser, path []string, val interface{}) (CheckedParser, error) {
+	if len(path) == 0 {
+		return parser.ParseChecked(val)
+	}
+
+	// the parent is not appended in place of the last key
+	parent, last := path[:len(path)-1:len(path)-1], path[len(path)-1]
+	container, ok := lookup(p, parent)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPath, pointer(parent))
+	}
+	array, ok := container.([]interface{})
+	if !ok {
+		return p, p.SetChecked(joinPath(path), val)
+	}
+
+	if last == "-" {
+		return p, p.SetChecked(joinPath(append(parent, "-1")), val)
+	}
+	index, err := strconv.Atoi(last)
+	if err != nil || index < 0 || index > len(array) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPath, pointer(path))
+	}
+	// shift the items after index
+	for i := len(array) - 1; i >= index; i-- {
+		item, err := p.GetChecked(joinPath(append(parent, strconv.Itoa(i))))
+		if err != nil {
+			return nil, err
+		}
+		if err := p.SetChecked(joinPath(append(parent, strconv.Itoa(i+1))), item); err != nil {
+			return nil, err
+		}
+	}
+	return p, p.SetChecked(joinPath(path), val)
+}
+
+func remove(p CheckedParser, path []string) error {
+	if len(path) == 0 {
+		return fmt.Errorf("%w: can not remove the root", ErrInvalidPath)
+	}
+	if _, err := p.GetChecked(joinPath(path)); err != nil {
+		return err
+	}
+	return p.Delete(joinPath(path))
+}
+
+// ApplyMerge applies the JSON Merge Patch to doc through parser, and returns
+// the parsed doc after the patch.
+func ApplyMerge(parser Parser, doc interface{}, patch json.RawMessage) (Parser, error) {
+	var tree interface{}
+	if err := decode(patch, &tree); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMarshal, err)
+	}
+
+	checker := checked(parser)
+	object, ok := tree.(map[string]interface{})
+	if !ok {
+		return checker.ParseChecked(tree)
+	}
+
+	p, err := checker.ParseChecked(doc)
+	if err != nil {
+		return nil, err
+	}
+	current, _ := lookup(p, nil)
+	if _, ok := current.(map[string]interface{}); !ok {
+		if p, err = checker.ParseChecked(map[string]interface{}{}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyMerge(p, nil, current, object); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// applyMerge merges the patch into the object at path, current is its value
+// before the patch.
+func applyMerge(p CheckedParser, path []string, current interface{}, patch map[string]interface{}) error {
+	object, ok := current.(map[string]interface{})
+	if !ok {
+		object = map[string]interface{}{}
+		if len(path) != 0 {
+			if err := p.SetChecked(joinPath(path), object); err != nil {
+				return err
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(patch))
+	for key := range patch {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		child := append(path, key)
+		switch val := patch[key].(type) {
+		case nil:
+			if _, ok := object[key]; ok {
+				if err := p.Delete(joinPath(child)); err != nil {
+					return err
+				}
+			}
+		case map[string]interface{}:
+			if err := applyMerge(p, child, object[key], val); err != nil {
+				return err
+			}
+		default:
+			if err := p.SetChecked(joinPath(child), val); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lookup returns the json value at path of the doc.
+func lookup(p CheckedParser, path []string) (interface{}, bool) {
+	val, err := treeOf(p)
+	if err != nil {
+		return nil, false
+	}
+
+	for _, key := range path {
+		switch v := val.(type) {
+		case map[string]interface{}:
+			child, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			val = child
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			val = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return val, true
+}
+
+// normalize converts val to its json value.
+func normalize(val interface{}) interface{} {
+	bytes, err := json.Marshal(val)
+	if err != nil {
+		return val
+	}
+	var temp interface{}
+	if err := decode(bytes, &temp); err != nil {
+		return val
+	}
+	return temp
+}
+
+// pointer joins the keys into a json pointer.
+func pointer(path []string) string {
+	var b strings.Builder
+	for _, key := range path {
+		b.WriteByte('/')
+		b.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(key))
+	}
+	return b.String()
+}
+
+func parsePointer(ptr string) ([]string, error) {
+	if ptr == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(ptr, "/") {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPath, ptr)
+	}
+
+	keys := strings.Split(ptr[1:], "/")
+	for i, key := range keys {
+		keys[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(key)
+	}
+	return keys, nil
+}
@@ -0,0 +1,120 @@
+package compare
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var patchSource = map[string]interface{}{
+	"name": "a",
+	"tags": []interface{}{"x", "y", "z"},
+	"meta": map[string]interface{}{"a/b": 1, "old": true},
+}
+
+var patchTarget = map[string]interface{}{
+	"name": "b",
+	"tags": []interface{}{"x"},
+	"meta": map[string]interface{}{"a/b": 2, "new": nil},
+	"age":  float64(3),
+}
+
+func TestPatch(t *testing.T) {
+	comparator := NewComparator(DefaultParser{})
+	patch, err := comparator.Patch(patchSource, patchTarget)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, Patch{
+		{Op: "add", Path: "/age", Value: json.Number("3")},
+		{Op: "replace", Path: "/meta/a~1b", Value: json.Number("2")},
+		{Op: "add", Path: "/meta/new", Value: nil},
+		{Op: "remove", Path: "/meta/old"},
+		{Op: "replace", Path: "/name", Value: "b"},
+		{Op: "remove", Path: "/tags/2"},
+		{Op: "remove", Path: "/tags/1"},
+	}, patch)
+
+	bytes, err := json.Marshal(patch[2])
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"op":"add","path":"/meta/new","value":null}`, string(bytes))
+
+	p, err := Apply(DefaultParser{}, patchSource, patch)
+	if assert.NoError(t, err) {
+		assert.True(t, comparator.Equal(json.RawMessage(p.Json()), patchTarget))
+	}
+}
+
+func TestApply(t *testing.T) {
+	var patch Patch
+	assert.NoError(t, json.Unmarshal([]byte(`[
+		{"op": "add", "path": "/tags/1", "value": "w"},
+		{"op": "add", "path": "/tags/-", "value": "end"},
+		{"op": "copy", "from": "/name", "path": "/alias"},
+		{"op": "move", "from": "/meta/old", "path": "/old"},
+		{"op": "test", "path": "/tags/0", "value": "x"}
+	]`), &patch))
+
+	p, err := Apply(DefaultParser{}, patchSource, patch)
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `{
+			"name": "a", "alias": "a", "old": true,
+			"tags": ["x", "w", "y", "z", "end"],
+			"meta": {"a/b": 1}
+		}`, p.Json())
+	}
+
+	_, err = Apply(DefaultParser{}, patchSource, Patch{{Op: "test", Path: "/name", Value: "b"}})
+	assert.Error(t, err)
+	_, err = Apply(DefaultParser{}, patchSource, Patch{{Op: "remove", Path: "/missing"}})
+	assert.ErrorIs(t, err, ErrInvalidPath)
+	_, err = Apply(DefaultParser{}, patchSource, Patch{{Op: "add", Path: "/tags/9", Value: 1}})
+	assert.ErrorIs(t, err, ErrInvalidPath)
+}
+
+func TestMergePatch(t *testing.T) {
+	comparator := NewComparator(DefaultParser{})
+	patch, err := comparator.MergePatch(patchSource, patchTarget)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.JSONEq(t, `{
+		"name": "b", "age": 3, "tags": ["x"],
+		"meta": {"a/b": 2, "new": null, "old": null}
+	}`, string(patch))
+
+	// the null value of target is removed by the merge patch
+	p, err := ApplyMerge(DefaultParser{}, patchSource, patch)
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `{"name": "b", "age": 3, "tags": ["x"], "meta": {"a/b": 2}}`, p.Json())
+	}
+
+	p, err = ApplyMerge(DefaultParser{}, []int{1}, json.RawMessage(`{"a": {"b": 1, "c": null}}`))
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `{"a": {"b": 1}}`, p.Json())
+	}
+}
+
+func TestPatchRules(t *testing.T) {
+	source := map[string]interface{}{"price": 10.004, "tags": []interface{}{"a", "b"}, "name": "a"}
+	target := map[string]interface{}{"price": 10, "tags": []interface{}{"b", "a"}, "name": "b"}
+	comparator := NewComparator(DefaultParser{}, FloatTolerance("price", 0.01), UnorderedArray("tags"))
+
+	// the values which are equal by the rules are left as they are
+	patch, err := comparator.Patch(source, target)
+	if assert.NoError(t, err) {
+		assert.Equal(t, Patch{{Op: "replace", Path: "/name", Value: "b"}}, patch)
+	}
+	merge, err := comparator.MergePatch(source, target)
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `{"name": "b"}`, string(merge))
+	}
+
+	target["name"] = "a"
+	assert.True(t, comparator.Equal(source, target))
+	patch, err = comparator.Patch(source, target)
+	if assert.NoError(t, err) {
+		assert.Empty(t, patch)
+	}
+}
@@ -0,0 +1,245 @@
+package compare
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/match"
+)
+
+// The paths of the options are patterns of gjson paths, the keys are
+// separated by dots and escaped by backslash. A key of a pattern is one of:
+//
+//	**       any number of keys, including none
+//	#        any index of an array
+//	*        any key or index, * and ? in a key match the same as gjson
+//	/regex/  the keys which match the regex, the dots are escaped as well
+//	name     the key itself
+
+type segmentKind int
+
+const (
+	literalKey segmentKind = iota
+	anyDepth
+	anyIndex
+	globKey
+	regexKey
+)
+
+type segment struct {
+	kind segmentKind
+	// text is the unescaped key of literalKey, or the glob of globKey
+	text string
+	re   *regexp.Regexp
+}
+
+type pattern []segment
+
+// compilePattern compiles the path, it returns ErrInvalidPath if a regex of
+// the path is invalid.
+func compilePattern(path string) (pattern, error) {
+	var p pattern
+	for _, raw := range splitPath(path) {
+		switch {
+		case raw == "**":
+			p = append(p, segment{kind: anyDepth})
+		case raw == "#":
+			p = append(p, segment{kind: anyIndex})
+		case len(raw) >= 2 && raw[0] == '/' && raw[len(raw)-1] == '/':
+			re, err := regexp.Compile(raw[1 : len(raw)-1])
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidPath, path, err)
+			}
+			p = append(p, segment{kind: regexKey, re: re})
+		case hasWildcard(raw):
+			p = append(p, segment{kind: globKey, text: raw})
+		default:
+			p = append(p, segment{kind: literalKey, text: unescapeKey(raw)})
+		}
+	}
+	return p, nil
+}
+
+// splitPath splits the path by the dots which are not escaped, the keys are
+// kept escaped.
+func splitPath(path string) []string {
+	var (
+		keys []string
+		b    strings.Builder
+	)
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '\\':
+			b.WriteByte(path[i])
+			if i+1 < len(path) {
+				i++
+				b.WriteByte(path[i])
+			}
+		case '.':
+			keys = append(keys, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(path[i])
+		}
+	}
+	return append(keys, b.String())
+}
+
+func hasWildcard(raw string) bool {
+	for i := 0; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			i++
+		case '*', '?':
+			return true
+		}
+	}
+	return false
+}
+
+func unescapeKey(raw string) string {
+	var b strings.Builder
+	for i := 0; i < len(raw); i++ {
+		if raw[i] == '\\' && i+1 < len(raw) {
+			i++
+		}
+		b.WriteByte(raw[i])
+	}
+	return b.String()
+}
+
+// matchKey reports whether the key matches the segment, index reports whether
+// the key is an index of an array.
+func (s segment) matchKey(key string, index bool) bool {
+	switch s.kind {
+	case anyIndex:
+		return index
+	case globKey:
+		return match.Match(key, s.text)
+	case regexKey:
+		return s.re.MatchString(key)
+	default:
+		return s.text == key
+	}
+}
+
+// match reports whether the keys of a path match the pattern, indexes reports
+// whether each key is an index of an array.
+func (p pattern) match(path []string, indexes []bool) bool {
+	if len(p) == 0 {
+		return len(path) == 0
+	}
+	if p[0].kind == anyDepth {
+		for i := 0; i <= len(path); i++ {
+			if p[1:].match(path[i:], indexes[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	return len(path) > 0 && p[0].matchKey(path[0], indexes[0]) && p[1:].match(path[1:], indexes[1:])
+}
+
+// expand returns the paths of the json tree which match the pattern.
+func (p pattern) expand(tree interface{}) [][]string {
+	var paths [][]string
+	p.walk(nil, tree, func(path []string) {
+		paths = append(paths, append([]string(nil), path...))
+	})
+	return paths
+}
+
+func (p pattern) walk(path []string, tree interface{}, found func([]string)) {
+	if len(p) == 0 {
+		found(path)
+		return
+	}
+	if p[0].kind == anyDepth {
+		p[1:].walk(path, tree, found)
+		children(tree, func(key string, child interface{}, _ bool) {
+			p.walk(append(path, key), child, found)
+		})
+		return
+	}
+	children(tree, func(key string, child interface{}, index bool) {
+		if p[0].matchKey(key, index) {
+			p[1:].walk(append(path, key), child, found)
+		}
+	})
+}
+
+// children calls fn with the children of an object in the order of their
+// keys, or the items of an array.
+func children(tree interface{}, fn func(key string, child interface{}, index bool)) {
+	switch val := tree.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for key := range val {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fn(key, val[key], false)
+		}
+	case []interface{}:
+		for i, item := range val {
+			fn(strconv.Itoa(i), item, true)
+		}
+	}
+}
+
+// lessPath orders the paths of a tree, the parents are before their children
+// and the indexes are in order.
+func lessPath(a, b []string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+		if isIndex(a[i]) && isIndex(b[i]) {
+			x, _ := strconv.Atoi(a[i])
+			y, _ := strconv.Atoi(b[i])
+			return x < y
+		}
+		return a[i] < b[i]
+	}
+	return len(a) < len(b)
+}
+
+func isIndex(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < '0' || key[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// matchRule returns the rule whose path matches path, the exact path is
+// preferred, then the longest pattern.
+func matchRule[V any](c Comparator, rules map[string]V, path []string, indexes []bool) (V, bool) {
+	if val, ok := rules[joinPath(path)]; ok {
+		return val, true
+	}
+
+	best, found := "", false
+	for key := range rules {
+		p, ok := c.patterns[key]
+		if !ok || !p.match(path, indexes) {
+			continue
+		}
+		if !found || len(key) > len(best) || len(key) == len(best) && key < best {
+			best, found = key, true
+		}
+	}
+	if !found {
+		var zero V
+		return zero, false
+	}
+	return rules[best], true
+}
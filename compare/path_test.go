@@ -0,0 +1,134 @@
+package compare
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatternMatch(t *testing.T) {
+	for _, item := range patternTable {
+		p, err := compilePattern(item.pattern)
+		if assert.NoError(t, err, item.pattern) {
+			// the numeric keys of the table are indexes
+			path := splitPath(item.path)
+			indexes := make([]bool, len(path))
+			for i, key := range path {
+				path[i], indexes[i] = unescapeKey(key), isIndex(key)
+			}
+			assert.Equal(t, item.except, p.match(path, indexes), "%s %s", item.pattern, item.path)
+		}
+	}
+
+	_, err := compilePattern("a./[/")
+	assert.ErrorIs(t, err, ErrInvalidPath)
+}
+
+var patternTable = []struct {
+	pattern string
+	path    string
+	except  bool
+}{
+	{"a.b", "a.b", true},
+	{"a.b", "a.c", false},
+	{`a\.b`, `a\.b`, true},
+	{`a\.b`, "a.b", false},
+	{"items.#.id", "items.3.id", true},
+	{"items.#.id", "items.x.id", false},
+	{"items.*.id", "items.x.id", true},
+	{"trace_*", "trace_id", true},
+	{"trace_?", "trace_id", false},
+	{"**.trace_id", "trace_id", true},
+	{"**.trace_id", "a.0.b.trace_id", true},
+	{"**.trace_id", "a.trace_id.b", false},
+	{"a.**", "a.b.c", true},
+	{"a.**.c", "a.c", true},
+	{"headers./^x-/", "headers.x-request-id", true},
+	{"headers./^x-/", "headers.accept", false},
+	{`/^a\.b$/`, `a\.b`, true},
+	{`/^a\.b$/`, "axb", false},
+}
+
+func TestPatternExpand(t *testing.T) {
+	var tree interface{}
+	assert.NoError(t, decode([]byte(`{
+		"trace_id": 1,
+		"items": [
+			{"id": 1, "tags": [{"trace_id": 2}, {"name": "a"}]},
+			{"id": 2, "tags": []}
+		]
+	}`), &tree))
+
+	p, _ := compilePattern("**.trace_id")
+	assert.Equal(t, [][]string{{"trace_id"}, {"items", "0", "tags", "0", "trace_id"}}, p.expand(tree))
+
+	p, _ = compilePattern("items.#.tags.#")
+	assert.Equal(t, [][]string{{"items", "0", "tags", "0"}, {"items", "0", "tags", "1"}}, p.expand(tree))
+
+	// the keys of an object are not indexes
+	p, _ = compilePattern("#")
+	assert.Empty(t, p.expand(tree))
+}
+
+func TestWildcardOption(t *testing.T) {
+	source := json.RawMessage(`{
+		"trace_id": "a",
+		"headers": {"x-request-id": "a", "accept": "json"},
+		"items": [
+			{"id": 1, "price": 1.0, "updated_at": 1, "children": [{"id": 3, "trace_id": "a", "updated_at": 1}]},
+			{"id": 2, "price": 2.0, "updated_at": 1}
+		]
+	}`)
+	target := json.RawMessage(`{
+		"trace_id": "b",
+		"headers": {"x-request-id": "b", "accept": "json"},
+		"items": [
+			{"id": 1, "price": 1.001, "updated_at": 2, "children": [{"id": 3, "trace_id": "b", "updated_at": 2}]},
+			{"id": 2, "price": 2.001, "updated_at": 2}
+		]
+	}`)
+
+	comparator := NewComparator(DefaultParser{},
+		ExcludeField("**.trace_id"),
+		ExcludeField("items.#.updated_at"),
+		ExcludeField("items.#.children.#.updated_at"),
+		ExcludeField("headers./^x-/"),
+		FloatTolerance("items.#.price", 0.01),
+	)
+	report, err := comparator.Diff(source, target)
+	if assert.NoError(t, err) {
+		assert.True(t, report.Equal(), report.String())
+	}
+
+	// the excluded items of an array are removed from the last one
+	comparator = NewComparator(DefaultParser{}, ExcludeField("items.1"), ExcludeField("items.0.children"))
+	report, err = comparator.Diff(source, target)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"headers.x-request-id", "items.0.price", "items.0.updated_at", "trace_id"}, paths(report))
+	}
+
+	comparator = NewComparator(DefaultParser{}, IncludeField("items.#.id"), IncludeField("items.#.children.#.trace_id"))
+	report, err = comparator.Diff(source, target)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"items.0.children.0.trace_id"}, paths(report))
+	}
+
+	// # matches the indexes only, not the numeric keys of an object
+	comparator = NewComparator(DefaultParser{}, FloatTolerance("*.#", 0.01))
+	report, err = comparator.Diff(json.RawMessage(`{"m": {"1": 1.0}, "a": [1.0]}`), json.RawMessage(`{"m": {"1": 1.001}, "a": [1.001]}`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"m.1"}, paths(report))
+	}
+
+	_, err = NewComparator(DefaultParser{}, ExcludeField("/(/")).Diff(source, target)
+	assert.ErrorIs(t, err, ErrInvalidPath)
+}
+
+func paths(report Report) []string {
+	paths := make([]string, len(report.Differences))
+	for i, d := range report.Differences {
+		paths[i] = d.Path
+	}
+	return paths
+}
@@ -0,0 +1,690 @@
+package compare
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ReflectParser parses the object by walking its value with reflection, the
+// same as json.Marshal does, into the json tree of map[string]interface{},
+// []interface{}, json.Number, string, bool and nil. It keeps the tree instead
+// of the json, so the comparison needs no encoding. The tree is still encoded
+// and decoded as the type, once the object is converted to another type or
+// the field options changed its tree.
+//
+// The paths are gjson paths of keys and indexes, the index of the length of
+// an array or -1 appends to it.
+type ReflectParser struct {
+	tree *interface{}
+}
+
+func (p ReflectParser) Parse(obj interface{}) Parser {
+	parsed, err := p.ParseChecked(obj)
+	if err != nil {
+		return ReflectParser{}
+	}
+	return parsed
+}
+
+func (p ReflectParser) ParseChecked(obj interface{}) (CheckedParser, error) {
+	tree, err := toTree(reflect.ValueOf(obj))
+	if err != nil {
+		return nil, err
+	}
+	return ReflectParser{tree: &tree}, nil
+}
+
+// Tree returns the json tree, which must not be changed.
+func (p ReflectParser) Tree() interface{} {
+	if p.tree == nil {
+		return nil
+	}
+	return *p.tree
+}
+
+// Get returns the json value at path, or nil if the path is missing.
+func (p ReflectParser) Get(path string) interface{} {
+	val, _ := p.GetChecked(path)
+	return val
+}
+
+func (p ReflectParser) GetChecked(path string) (interface{}, error) {
+	val := p.Tree()
+	for _, key := range keys(path) {
+		child, ok := childOf(val, key)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPath, path)
+		}
+		val = child
+	}
+	return val, nil
+}
+
+func (p ReflectParser) Set(path string, value interface{}) {
+	_ = p.SetChecked(path, value)
+}
+
+// SetChecked sets the value at path, the missing parents are created as
+// objects, or arrays if their key is an index.
+func (p ReflectParser) SetChecked(path string, value interface{}) error {
+	if p.tree == nil {
+		return ErrNotParsed
+	}
+
+	val, err := toTree(reflect.ValueOf(value))
+	if err != nil {
+		return err
+	}
+	tree, err := setPath(*p.tree, keys(path), val)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidPath, path, err)
+	}
+	*p.tree = tree
+	return nil
+}
+
+func (p ReflectParser) Delete(path string) error {
+	if path == "" {
+		return fmt.Errorf("%w: path is empty", ErrInvalidPath)
+	}
+	if p.tree == nil {
+		// nothing to delete in the empty document
+		return nil
+	}
+	*p.tree = deletePath(*p.tree, keys(path))
+	return nil
+}
+
+func (p ReflectParser) Json() string {
+	if p.tree == nil {
+		return ""
+	}
+	bytes, _ := json.Marshal(*p.tree)
+	return string(bytes)
+}
+
+// keys splits the path into the unescaped keys.
+func keys(path string) []string {
+	if path == "" {
+		return nil
+	}
+	keys := splitPath(path)
+	for i, key := range keys {
+		keys[i] = unescapeKey(key)
+	}
+	return keys
+}
+
+func childOf(val interface{}, key string) (interface{}, bool) {
+	switch v := val.(type) {
+	case map[string]interface{}:
+		child, ok := v[key]
+		return child, ok
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(v) {
+			return nil, false
+		}
+		return v[i], true
+	default:
+		return nil, false
+	}
+}
+
+func setPath(tree interface{}, path []string, val interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return val, nil
+	}
+
+	key := path[0]
+	switch v := tree.(type) {
+	case map[string]interface{}:
+		child, err := setPath(v[key], path[1:], val)
+		if err != nil {
+			return nil, err
+		}
+		v[key] = child
+		return v, nil
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < -1 {
+			return nil, fmt.Errorf("invalid index %q", key)
+		}
+		if i == -1 {
+			i = len(v)
+		}
+		for len(v) <= i {
+			v = append(v, nil)
+		}
+		if v[i], err = setPath(v[i], path[1:], val); err != nil {
+			return nil, err
+		}
+		return v, nil
+	case nil:
+		// create the missing parent
+		if i, err := strconv.Atoi(key); err == nil && i >= -1 {
+			return setPath([]interface{}{}, path, val)
+		}
+		return setPath(map[string]interface{}{}, path, val)
+	default:
+		return nil, fmt.Errorf("%q of a %s", key, jsonType(tree))
+	}
+}
+
+func deletePath(tree interface{}, path []string) interface{} {
+	key := path[0]
+	switch v := tree.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			delete(v, key)
+		} else if child, ok := v[key]; ok {
+			v[key] = deletePath(child, path[1:])
+		}
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(v) {
+			return v
+		}
+		if len(path) == 1 {
+			return append(v[:i], v[i+1:]...)
+		}
+		v[i] = deletePath(v[i], path[1:])
+	}
+	return tree
+}
+
+var (
+	jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	numberType    = reflect.TypeOf(json.Number(""))
+	objectType    = reflect.TypeOf(map[string]interface{}(nil))
+	arrayType     = reflect.TypeOf([]interface{}(nil))
+)
+
+// marshals reports whether the value marshals itself.
+func marshals(v reflect.Value) bool {
+	for _, m := range []reflect.Type{jsonMarshaler, textMarshaler} {
+		if v.Type().Implements(m) || v.CanAddr() && v.Addr().Type().Implements(m) {
+			return true
+		}
+	}
+	return false
+}
+
+// toTree converts the value to its json tree, the same as json.Marshal.
+func toTree(v reflect.Value) (interface{}, error) {
+	return new(treeState).tree(v)
+}
+
+// startDetectingCycles is the depth of the references after which the cycles
+// are detected, the same as json.Marshal, so the shallow values skip it.
+const startDetectingCycles = 1000
+
+// treeState tracks the references on the way to the value being converted.
+type treeState struct {
+	level int
+	seen  map[interface{}]None
+}
+
+// reference returns the identity of the pointer, map or slice, the slices
+// of different lengths over the same array are different values.
+func reference(v reflect.Value) (interface{}, bool) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map:
+		return v.Pointer(), !v.IsNil()
+	case reflect.Slice:
+		return struct {
+			ptr uintptr
+			len int
+		}{v.Pointer(), v.Len()}, !v.IsNil()
+	default:
+		return nil, false
+	}
+}
+
+// enter goes through the reference, and fails if it is already on the way.
+func (s *treeState) enter(ref interface{}, typ reflect.Type) error {
+	if s.level++; s.level <= startDetectingCycles {
+		return nil
+	}
+	if s.seen == nil {
+		s.seen = make(map[interface{}]None)
+	}
+	if _, ok := s.seen[ref]; ok {
+		s.level--
+		return fmt.Errorf("%w: encountered a cycle via %s", ErrMarshal, typ)
+	}
+	s.seen[ref] = None{}
+	return nil
+}
+
+func (s *treeState) leave(ref interface{}) {
+	if s.level--; s.seen != nil {
+		delete(s.seen, ref)
+	}
+}
+
+func (s *treeState) tree(v reflect.Value) (interface{}, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if ref, ok := reference(v); ok {
+		if err := s.enter(ref, v.Type()); err != nil {
+			return nil, err
+		}
+		defer s.leave(ref)
+	}
+
+	// the values of the tree are copied without reflection
+	switch typ := v.Type(); {
+	case !v.CanInterface():
+	case typ == numberType:
+		if v.String() == "" {
+			return json.Number("0"), nil
+		}
+		return json.Number(v.String()), nil
+	case typ == objectType:
+		if v.IsNil() {
+			return nil, nil
+		}
+		object := make(map[string]interface{}, v.Len())
+		for key, child := range v.Interface().(map[string]interface{}) {
+			tree, err := s.tree(reflect.ValueOf(child))
+			if err != nil {
+				return nil, err
+			}
+			object[key] = tree
+		}
+		return object, nil
+	case typ == arrayType:
+		if v.IsNil() {
+			return nil, nil
+		}
+		array := make([]interface{}, v.Len())
+		for i, child := range v.Interface().([]interface{}) {
+			tree, err := s.tree(reflect.ValueOf(child))
+			if err != nil {
+				return nil, err
+			}
+			array[i] = tree
+		}
+		return array, nil
+	}
+
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return nil, nil
+	}
+	// the unexported values are not able to marshal themselves
+	if v.CanInterface() && marshals(v) {
+		return marshalTree(v)
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return json.Number(strconv.FormatInt(v.Int(), 10)), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return json.Number(strconv.FormatUint(v.Uint(), 10)), nil
+	case reflect.Float32, reflect.Float64:
+		return formatFloat(v.Float(), v.Type().Bits())
+	case reflect.Interface, reflect.Pointer:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return s.tree(v.Elem())
+	case reflect.Struct:
+		return s.structTree(v)
+	case reflect.Map:
+		return s.mapTree(v)
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return base64.StdEncoding.EncodeToString(v.Bytes()), nil
+		}
+		fallthrough
+	case reflect.Array:
+		array := make([]interface{}, v.Len())
+		for i := range array {
+			tree, err := s.tree(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			array[i] = tree
+		}
+		return array, nil
+	default:
+		return nil, fmt.Errorf("%w: unsupported type %s", ErrMarshal, v.Type())
+	}
+}
+
+// marshalTree converts the value which marshals itself by its json.
+func marshalTree(v reflect.Value) (interface{}, error) {
+	if v.CanAddr() {
+		v = v.Addr()
+	}
+	bytes, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMarshal, err)
+	}
+	var tree interface{}
+	if err := decode(bytes, &tree); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMarshal, err)
+	}
+	return tree, nil
+}
+
+// formatFloat formats the float the same as json.Marshal.
+func formatFloat(f float64, bits int) (interface{}, error) {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil, fmt.Errorf("%w: unsupported value %v", ErrMarshal, f)
+	}
+
+	format, abs := byte('f'), math.Abs(f)
+	if abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	str := strconv.FormatFloat(f, format, -1, bits)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		if n := len(str); n >= 4 && str[n-4] == 'e' && str[n-3] == '-' && str[n-2] == '0' {
+			str = str[:n-2] + str[n-1:]
+		}
+	}
+	return json.Number(str), nil
+}
+
+func (s *treeState) structTree(v reflect.Value) (interface{}, error) {
+	object := make(map[string]interface{})
+	for _, f := range cachedFields(v.Type()) {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || f.omitEmpty && isEmpty(fv) || f.omitZero && isZero(fv) {
+			continue
+		}
+
+		tree, err := s.tree(fv)
+		if err != nil {
+			return nil, err
+		}
+		if f.quoted {
+			switch val := tree.(type) {
+			case json.Number:
+				tree = string(val)
+			case bool:
+				tree = strconv.FormatBool(val)
+			case string:
+				bytes, _ := json.Marshal(val)
+				tree = string(bytes)
+			}
+		}
+		object[f.name] = tree
+	}
+	return object, nil
+}
+
+// fieldByIndex returns the field, it is false if an embedded pointer is nil.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func (s *treeState) mapTree(v reflect.Value) (interface{}, error) {
+	if v.IsNil() {
+		return nil, nil
+	}
+
+	object := make(map[string]interface{}, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := mapKey(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		tree, err := s.tree(iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		object[key] = tree
+	}
+	return object, nil
+}
+
+func mapKey(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok && k.Kind() != reflect.String {
+		if k.Kind() == reflect.Pointer && k.IsNil() {
+			return "", nil
+		}
+		text, err := tm.MarshalText()
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrMarshal, err)
+		}
+		return string(text), nil
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	default:
+		return "", fmt.Errorf("%w: unsupported map key %s", ErrMarshal, k.Type())
+	}
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	default:
+		return false
+	}
+}
+
+// isZero reports whether the value is zero the same as encoding/json does for
+// omitzero, by its IsZero method if the type has one.
+func isZero(v reflect.Value) bool {
+	switch {
+	case v.Type().Implements(zeroerType):
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return true
+		}
+		return v.Interface().(zeroer).IsZero()
+	case reflect.PointerTo(v.Type()).Implements(zeroerType):
+		if !v.CanAddr() {
+			temp := reflect.New(v.Type()).Elem()
+			temp.Set(v)
+			v = temp
+		}
+		return v.Addr().Interface().(zeroer).IsZero()
+	default:
+		return v.IsZero()
+	}
+}
+
+type zeroer interface {
+	IsZero() bool
+}
+
+var zeroerType = reflect.TypeOf((*zeroer)(nil)).Elem()
+
+// field is a json field of a struct.
+type field struct {
+	name      string
+	index     []int
+	omitEmpty bool
+	omitZero  bool
+	quoted    bool
+	// tagged reports whether the name is given by the tag
+	tagged bool
+}
+
+var fieldCache sync.Map // map[reflect.Type][]field
+
+// cachedFields returns the json fields of the struct type, they are collected
+// once per type.
+func cachedFields(t reflect.Type) []field {
+	if fields, ok := fieldCache.Load(t); ok {
+		return fields.([]field)
+	}
+	fields, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return fields.([]field)
+}
+
+// typeFields collects the fields the same as encoding/json, the fields of the
+// embedded structs are promoted unless a shallower one has the same name.
+func typeFields(t reflect.Type) []field {
+	type embedded struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	var (
+		fields  []field
+		current = []embedded{{typ: t}}
+		visited = map[reflect.Type]bool{}
+	)
+	for len(current) > 0 {
+		var (
+			next  []embedded
+			level = map[string][]field{}
+			names []string
+		)
+		for _, e := range current {
+			if visited[e.typ] {
+				continue
+			}
+			visited[e.typ] = true
+
+			for i := 0; i < e.typ.NumField(); i++ {
+				sf := e.typ.Field(i)
+				ft := sf.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if !sf.IsExported() && !(sf.Anonymous && ft.Kind() == reflect.Struct) {
+					continue
+				}
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+
+				name, opts, _ := strings.Cut(tag, ",")
+				index := append(append([]int(nil), e.index...), i)
+				if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+					next = append(next, embedded{typ: ft, index: index})
+					continue
+				}
+				if !sf.IsExported() {
+					continue
+				}
+
+				f := field{name: name, index: index, tagged: name != ""}
+				if name == "" {
+					f.name = sf.Name
+				}
+				for _, opt := range strings.Split(opts, ",") {
+					switch opt {
+					case "omitempty":
+						f.omitEmpty = true
+					case "omitzero":
+						f.omitZero = true
+					case "string":
+						switch ft.Kind() {
+						case reflect.Bool, reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+							reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+							reflect.Float32, reflect.Float64:
+							f.quoted = true
+						}
+					}
+				}
+				if _, ok := level[f.name]; !ok {
+					names = append(names, f.name)
+				}
+				level[f.name] = append(level[f.name], f)
+			}
+		}
+
+		for _, name := range names {
+			if containsField(fields, name) {
+				continue
+			}
+			// the tagged one wins among the fields of the same depth, the
+			// field is dropped if it is still ambiguous
+			candidates := level[name]
+			var tagged []field
+			for _, f := range candidates {
+				if f.tagged {
+					tagged = append(tagged, f)
+				}
+			}
+			switch {
+			case len(candidates) == 1:
+				fields = append(fields, candidates[0])
+			case len(tagged) == 1:
+				fields = append(fields, tagged[0])
+			default:
+				// keep the name so the deeper ones are hidden as well
+				fields = append(fields, field{name: name})
+			}
+		}
+		current = next
+	}
+
+	valid := fields[:0]
+	for _, f := range fields {
+		if f.index != nil {
+			valid = append(valid, f)
+		}
+	}
+	sort.SliceStable(valid, func(i, j int) bool {
+		return lessIndex(valid[i].index, valid[j].index)
+	})
+	return valid
+}
+
+func containsField(fields []field, name string) bool {
+	for _, f := range fields {
+		if f.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func lessIndex(a, b []int) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
@@ -0,0 +1,62 @@
+package compare
+
+import (
+	"fmt"
+	"testing"
+)
+
+type benchOrder struct {
+	ID     int64             `json:"id"`
+	Status string            `json:"status"`
+	Labels map[string]string `json:"labels"`
+	Items  []benchItem       `json:"items"`
+}
+
+type benchItem struct {
+	SKU   string  `json:"sku"`
+	Price float64 `json:"price"`
+	Count int     `json:"count"`
+}
+
+// generateBenchmarkOrder generates an order with size items.
+func generateBenchmarkOrder(size int) benchOrder {
+	order := benchOrder{ID: 1, Status: "paid", Labels: map[string]string{"channel": "web"}}
+	for i := 0; i < size; i++ {
+		order.Items = append(order.Items, benchItem{SKU: fmt.Sprintf("sku-%d", i), Price: float64(i) / 10, Count: i})
+	}
+	return order
+}
+
+func benchmarkEqual(b *testing.B, parser Parser, size int, opts ...Option) {
+	source, target := generateBenchmarkOrder(size), generateBenchmarkOrder(size)
+	comparator := NewComparator(parser, opts...)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !comparator.Equal(source, target) {
+			b.Fatal("not equal")
+		}
+	}
+}
+
+func BenchmarkEqual(b *testing.B) {
+	parsers := []struct {
+		name   string
+		parser Parser
+	}{
+		{"DefaultParser", DefaultParser{}},
+		{"ReflectParser", ReflectParser{}},
+	}
+
+	for _, size := range []int{10, 100} {
+		for _, p := range parsers {
+			b.Run(fmt.Sprintf("%s/%d", p.name, size), func(b *testing.B) {
+				benchmarkEqual(b, p.parser, size)
+			})
+			b.Run(fmt.Sprintf("%s/%d/exclude", p.name, size), func(b *testing.B) {
+				benchmarkEqual(b, p.parser, size, ExcludeField("items.#.count"))
+			})
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package compare
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Base struct {
+	ID      int64  `json:"id"`
+	Comment string `json:"comment"`
+}
+
+type audit struct {
+	Creator string
+}
+
+type order struct {
+	Base
+	*audit
+	Comment  string            `json:"comment"`
+	Price    float64           `json:"price,omitempty"`
+	Rate     float32           `json:"rate"`
+	Big      float64           `json:"big"`
+	Small    float64           `json:"small"`
+	Count    int               `json:"count,string"`
+	Secret   string            `json:"-"`
+	Hidden   string            `json:"hidden,omitempty"`
+	Raw      []byte            `json:"raw"`
+	Labels   map[int]string    `json:"labels"`
+	Items    []*item           `json:"items"`
+	Extra    interface{}       `json:"extra"`
+	At       time.Time         `json:"at"`
+	Numbers  [2]uint8          `json:"numbers"`
+	Optional *string           `json:"optional"`
+	Dynamic  map[string]string `json:"dynamic,omitempty"`
+	private  int
+}
+
+type item struct {
+	Name string          `json:"name"`
+	Data json.RawMessage `json:"data"`
+}
+
+func TestReflectParse(t *testing.T) {
+	obj := order{
+		Base:    Base{ID: 9007199254740993, Comment: "hidden by the outer one"},
+		audit:   &audit{Creator: "a"},
+		Comment: "c",
+		Rate:    0.1,
+		Big:     1e21,
+		Small:   1e-7,
+		Count:   3,
+		Secret:  "s",
+		Raw:     []byte("raw"),
+		Labels:  map[int]string{1: "one"},
+		Items:   []*item{{Name: "x", Data: json.RawMessage(`{"a": [1]}`)}, nil},
+		Extra:   map[string]interface{}{"n": 1.5},
+		At:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		private: 1,
+	}
+
+	p, err := ReflectParser{}.ParseChecked(obj)
+	if !assert.NoError(t, err) {
+		return
+	}
+	except, _ := json.Marshal(obj)
+	assert.JSONEq(t, string(except), p.Json())
+
+	tree := p.(ReflectParser).Tree().(map[string]interface{})
+	assert.Equal(t, json.Number("9007199254740993"), tree["id"])
+	assert.Equal(t, json.Number("1e+21"), tree["big"])
+	assert.Equal(t, json.Number("1e-7"), tree["small"])
+	assert.Equal(t, json.Number("0.1"), tree["rate"])
+
+	_, err = ReflectParser{}.ParseChecked(make(chan int))
+	assert.ErrorIs(t, err, ErrMarshal)
+}
+
+// money is zero without cents, whatever the currency is.
+type money struct {
+	Cents    int    `json:"cents"`
+	Currency string `json:"currency"`
+}
+
+func (m *money) IsZero() bool {
+	return m.Cents == 0
+}
+
+type zeros struct {
+	At    time.Time  `json:"at,omitzero"`
+	Count int        `json:"count,omitzero"`
+	Price money      `json:"price,omitzero"`
+	Next  *time.Time `json:"next,omitzero"`
+	Kept  time.Time  `json:"kept,omitempty"`
+}
+
+func TestReflectParseOmitZero(t *testing.T) {
+	for _, obj := range []zeros{
+		{Price: money{Currency: "usd"}},
+		{At: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Count: 1, Price: money{Cents: 1}, Next: &time.Time{}},
+	} {
+		p, err := ReflectParser{}.ParseChecked(obj)
+		if !assert.NoError(t, err) {
+			return
+		}
+		except, _ := json.Marshal(obj)
+		assert.JSONEq(t, string(except), p.Json())
+	}
+
+	p, _ := ReflectParser{}.ParseChecked(zeros{Price: money{Currency: "usd"}})
+	assert.JSONEq(t, `{"kept": "0001-01-01T00:00:00Z"}`, p.Json())
+}
+
+type node struct {
+	Name string `json:"name"`
+	Next *node  `json:"next"`
+}
+
+func TestReflectParseCycle(t *testing.T) {
+	ring := &node{Name: "a"}
+	ring.Next = &node{Name: "b", Next: ring}
+	_, err := json.Marshal(ring)
+	assert.Error(t, err)
+	_, err = ReflectParser{}.ParseChecked(ring)
+	assert.ErrorIs(t, err, ErrMarshal)
+
+	self := map[string]interface{}{}
+	self["self"] = self
+	_, err = ReflectParser{}.ParseChecked(self)
+	assert.ErrorIs(t, err, ErrMarshal)
+
+	// the shared values which are not a cycle are converted
+	shared := &node{Name: "shared"}
+	p, err := ReflectParser{}.ParseChecked([]*node{shared, shared})
+	if assert.NoError(t, err) {
+		assert.JSONEq(t, `[{"name": "shared", "next": null}, {"name": "shared", "next": null}]`, p.Json())
+	}
+}
+
+func TestReflectParserPath(t *testing.T) {
+	p, err := ReflectParser{}.ParseChecked(map[string]interface{}{"a.b": 1, "list": []int{1, 2, 3}})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	val, err := p.GetChecked(`a\.b`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, json.Number("1"), val)
+	}
+	_, err = p.GetChecked("list.3")
+	assert.ErrorIs(t, err, ErrInvalidPath)
+
+	assert.NoError(t, p.SetChecked("new.0.name", "x"))
+	assert.NoError(t, p.SetChecked("list.-1", 4))
+	assert.NoError(t, p.Delete("list.0"))
+	assert.NoError(t, p.Delete("missing.key"))
+	assert.ErrorIs(t, p.SetChecked("list.x", 1), ErrInvalidPath)
+	assert.JSONEq(t, `{"a.b": 1, "list": [2, 3, 4], "new": [{"name": "x"}]}`, p.Json())
+
+	// the value set is copied
+	list, _ := p.GetChecked("list")
+	assert.NoError(t, p.SetChecked("copy", list))
+	assert.NoError(t, p.Delete("copy.0"))
+	assert.JSONEq(t, `[2, 3, 4]`, mustJson(p, "list"))
+}
+
+func mustJson(p CheckedParser, path string) string {
+	val, _ := p.GetChecked(path)
+	bytes, _ := json.Marshal(val)
+	return string(bytes)
+}
+
+func TestReflectParserOptions(t *testing.T) {
+	type account struct {
+		Name string `json:"name"`
+		Nick string `json:"nick"`
+	}
+
+	// the options change the tree, which is converted to the type again
+	for _, opts := range [][]Option{
+		{FiledPathMap("name", "alias")},
+		{ExcludeField("nick")},
+		{IncludeField("name")},
+	} {
+		source, target := account{Name: "a", Nick: "x"}, account{Name: "a", Nick: "y"}
+		except, err := NewComparator(DefaultParser{}, opts...).Diff(source, target)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		report, err := NewComparator(ReflectParser{}, opts...).Diff(source, target)
+		if assert.NoError(t, err) {
+			assert.Equal(t, except, report)
+		}
+	}
+}
+
+func TestReflectParserDiff(t *testing.T) {
+	for _, item := range diffTable {
+		comparator := NewComparator(ReflectParser{}, item.option...)
+		report, err := comparator.Diff(item.source, item.target)
+		if assert.NoError(t, err, item.name) {
+			assert.Equal(t, item.except, report.Differences, item.name)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package compare
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The fields of a struct are able to give their rules by the compare tag, the
+// rules are separated by commas:
+//
+//	-               ignore the field, the same as ExcludeField
+//	unordered       compare the array as a multiset, the same as UnorderedArray
+//	tolerance=0.01  allow the numbers to differ, the same as FloatTolerance
+//	key=id          match the objects of the array by id, the same as ArrayKey
+//
+// The rules are collected from the types of source and target, the paths of
+// the fields are their json names. The rules of a recursive type apply at any
+// depth below its first field. The options take precedence over the tags,
+// however specific the paths of the tags are, only the excluded fields of
+// both of them are merged.
+
+// tagRule is the rule of a field given by its compare tag.
+type tagRule struct {
+	path      string
+	exclude   bool
+	unordered bool
+	tolerance *float64
+	key       string
+}
+
+var tagCache sync.Map // map[reflect.Type]tagRules
+
+type tagRules struct {
+	rules []tagRule
+	err   error
+}
+
+// typeRules returns the rules of the type, they are collected once per type.
+func typeRules(t reflect.Type) ([]tagRule, error) {
+	if cached, ok := tagCache.Load(t); ok {
+		return cached.(tagRules).rules, cached.(tagRules).err
+	}
+
+	var rules []tagRule
+	err := collectTags(t, nil, make(map[reflect.Type]bool), make(map[reflect.Type]bool), &rules)
+	cached, _ := tagCache.LoadOrStore(t, tagRules{rules: rules, err: err})
+	return cached.(tagRules).rules, cached.(tagRules).err
+}
+
+// collectTags collects the rules of the fields of t, path is the pattern of t.
+// The rules of a recursive type are anchored by ** after its path, so they
+// apply to the nested values of the type as well.
+func collectTags(t reflect.Type, path []string, visiting, recursive map[reflect.Type]bool, rules *[]tagRule) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	// the types which marshal themselves have no fields
+	if t.Implements(jsonMarshaler) || reflect.PointerTo(t).Implements(jsonMarshaler) ||
+		t.Implements(textMarshaler) || reflect.PointerTo(t).Implements(textMarshaler) {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		// the recursive type is collected once, and anchored by ** at last
+		if visiting[t] {
+			recursive[t] = true
+			return nil
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		defer anchor(t, path, len(*rules), recursive, rules)
+
+		for _, f := range cachedFields(t) {
+			sf := t.FieldByIndex(f.index)
+			fieldPath := append(path[:len(path):len(path)], escapeKey(f.name))
+
+			rule, err := parseTag(strings.Join(fieldPath, "."), sf.Tag.Get("compare"))
+			if err != nil {
+				return fmt.Errorf("%s.%s: %w", t, sf.Name, err)
+			}
+			if rule.exclude || rule.unordered || rule.tolerance != nil || rule.key != "" {
+				*rules = append(*rules, rule)
+			}
+			if rule.exclude {
+				continue
+			}
+			if err := collectTags(sf.Type, fieldPath, visiting, recursive, rules); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		// []byte is a string of base64
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return nil
+		}
+		return collectTags(t.Elem(), append(path[:len(path):len(path)], "#"), visiting, recursive, rules)
+	case reflect.Map:
+		return collectTags(t.Elem(), append(path[:len(path):len(path)], "*"), visiting, recursive, rules)
+	}
+	return nil
+}
+
+// anchor inserts ** after path into the rules of the recursive type t, which
+// are the ones from start.
+func anchor(t reflect.Type, path []string, start int, recursive map[reflect.Type]bool, rules *[]tagRule) {
+	if !recursive[t] {
+		return
+	}
+	delete(recursive, t)
+
+	base := strings.Join(path, ".")
+	for i := start; i < len(*rules); i++ {
+		rule := &(*rules)[i]
+		rest := strings.TrimPrefix(rule.path[len(base):], ".")
+		rule.path = strings.TrimPrefix(base+".**."+rest, ".")
+	}
+}
+
+func parseTag(path, tag string) (tagRule, error) {
+	rule := tagRule{path: path}
+	if tag == "" {
+		return rule, nil
+	}
+
+	for _, opt := range strings.Split(tag, ",") {
+		name, val, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		switch name {
+		case "-":
+			rule.exclude = true
+		case "unordered":
+			rule.unordered = true
+		case "tolerance":
+			tol, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				return rule, fmt.Errorf("invalid compare tag %q: %v", tag, err)
+			}
+			rule.tolerance = &tol
+		case "key":
+			if val == "" {
+				return rule, fmt.Errorf("invalid compare tag %q: key is empty", tag)
+			}
+			rule.key = val
+		case "":
+		default:
+			return rule, fmt.Errorf("invalid compare tag %q: unknown rule %q", tag, name)
+		}
+	}
+	return rule, nil
+}
+
+// withTags returns the comparator with the rules of the tags of the types of
+// source and target, the comparator is cached for the types.
+func (c Comparator) withTags(source, target interface{}) (Comparator, error) {
+	if c.tagged == nil {
+		return c, nil
+	}
+
+	types := [2]reflect.Type{reflect.TypeOf(source), reflect.TypeOf(target)}
+	if cached, ok := c.tagged.Load(types); ok {
+		return cached.(Comparator), nil
+	}
+
+	var rules []tagRule
+	for _, t := range types {
+		if t == nil {
+			continue
+		}
+		tRules, err := typeRules(t)
+		if err != nil {
+			return c, err
+		}
+		rules = append(rules, tRules...)
+	}
+	if len(rules) == 0 {
+		c.tagged.Store(types, c)
+		return c, nil
+	}
+
+	// the rules of the tags are kept apart, so they are looked up only when
+	// no option matches
+	derived, tags := c.clone(), NewComparator(c.parser)
+	derived.tagged, tags.tagged = nil, nil
+	for _, rule := range rules {
+		if rule.exclude {
+			ExcludeField(rule.path)(&derived)
+		}
+		if rule.unordered {
+			UnorderedArray(rule.path)(&tags)
+		}
+		if rule.tolerance != nil {
+			FloatTolerance(rule.path, *rule.tolerance)(&tags)
+		}
+		if rule.key != "" {
+			ArrayKey(rule.path, rule.key)(&tags)
+		}
+	}
+	if err := errors.Join(derived.err, tags.err); err != nil {
+		return c, err
+	}
+	derived.tags = &tags
+
+	cached, _ := c.tagged.LoadOrStore(types, derived)
+	return cached.(Comparator), nil
+}
+
+// clone copies the rules of the comparator, so the copy is able to add rules.
+func (c Comparator) clone() Comparator {
+	clone := c
+	clone.include = cloneMap(c.include)
+	clone.exclude = cloneMap(c.exclude)
+	clone.pathMap = cloneMap(c.pathMap)
+	clone.tolerance = cloneMap(c.tolerance)
+	clone.unordered = cloneMap(c.unordered)
+	clone.arrayKey = cloneMap(c.arrayKey)
+	clone.custom = cloneMap(c.custom)
+	clone.patterns = cloneMap(c.patterns)
+	return clone
+}
+
+func cloneMap[K comparable, V any](m map[K]V) map[K]V {
+	clone := make(map[K]V, len(m))
+	for k, v := range m {
+		clone[k] = v
+	}
+	return clone
+}
@@ -0,0 +1,135 @@
+package compare
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type taggedOrder struct {
+	ID        string            `json:"id"`
+	UpdatedAt string            `json:"updated_at" compare:"-"`
+	Tags      []string          `json:"tags" compare:"unordered"`
+	Total     float64           `json:"total" compare:"tolerance=0.01"`
+	Lines     []taggedLine      `json:"lines" compare:"key=sku"`
+	Shipments map[string]*Ship  `json:"shipments"`
+	Meta      map[string]string `json:"meta,omitempty"`
+}
+
+type taggedLine struct {
+	SKU   string  `json:"sku"`
+	Price float64 `json:"price" compare:"tolerance=0.001"`
+	Trace string  `json:"trace" compare:"-"`
+}
+
+type Ship struct {
+	Carrier string `json:"carrier"`
+	Tracked string `json:"tracked" compare:"-"`
+	Next    *Ship  `json:"next"`
+}
+
+func TestTags(t *testing.T) {
+	source := taggedOrder{
+		ID:        "1",
+		UpdatedAt: "yesterday",
+		Tags:      []string{"a", "b"},
+		Total:     10.004,
+		Lines:     []taggedLine{{SKU: "x", Price: 1.0001, Trace: "a"}, {SKU: "y", Price: 2}},
+		Shipments: map[string]*Ship{"s1": {Carrier: "ups", Tracked: "a", Next: &Ship{Tracked: "a"}}},
+	}
+	target := taggedOrder{
+		ID:        "1",
+		UpdatedAt: "today",
+		Tags:      []string{"b", "a"},
+		Total:     10,
+		Lines:     []taggedLine{{SKU: "y", Price: 2}, {SKU: "x", Price: 1, Trace: "b"}},
+		Shipments: map[string]*Ship{"s1": {Carrier: "ups", Tracked: "b", Next: &Ship{Tracked: "b"}}},
+	}
+
+	for _, parser := range []Parser{DefaultParser{}, ReflectParser{}} {
+		comparator := NewComparator(parser)
+		report, err := comparator.Diff(source, target)
+		if assert.NoError(t, err) {
+			assert.True(t, report.Equal(), report.String())
+		}
+
+		target.Lines[1].Price = 1.1
+		report, err = comparator.Diff(source, target)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []string{"lines.0.price"}, paths(report))
+		}
+		target.Lines[1].Price = 1
+	}
+}
+
+func TestTagsPrecedence(t *testing.T) {
+	source := taggedOrder{Total: 10.004, UpdatedAt: "a"}
+	target := taggedOrder{Total: 10, UpdatedAt: "b"}
+
+	// the option takes the place of the tag
+	comparator := NewComparator(DefaultParser{}, FloatTolerance("total", 0.001))
+	report, err := comparator.Diff(source, target)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"total"}, paths(report))
+	}
+
+	// the option wins over the more specific tag
+	comparator = NewComparator(DefaultParser{}, FloatTolerance("**.price", 0))
+	report, err = comparator.Diff(
+		taggedOrder{Lines: []taggedLine{{SKU: "x", Price: 1.0001}}},
+		taggedOrder{Lines: []taggedLine{{SKU: "x", Price: 1}}},
+	)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"lines.0.price"}, paths(report))
+	}
+	comparator = NewComparator(DefaultParser{}, UnorderedArray("*"))
+	report, err = comparator.Diff(
+		taggedOrder{Lines: []taggedLine{{SKU: "x"}, {SKU: "y", Price: 1}}},
+		taggedOrder{Lines: []taggedLine{{SKU: "y", Price: 2}, {SKU: "x"}}},
+	)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"lines.1", "lines.0"}, paths(report))
+	}
+
+	// the tags of the source are applied as well
+	doc := map[string]interface{}{"id": "", "updated_at": "c", "tags": nil, "total": 10, "lines": nil, "shipments": nil}
+	report, err = NewComparator(DefaultParser{}).Diff(source, doc)
+	if assert.NoError(t, err) {
+		assert.True(t, report.Equal(), report.String())
+	}
+}
+
+func TestTypeRules(t *testing.T) {
+	rules, err := typeRules(reflect.TypeOf(&taggedOrder{}))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tolerance := func(f float64) *float64 { return &f }
+	assert.Equal(t, []tagRule{
+		{path: "updated_at", exclude: true},
+		{path: "tags", unordered: true},
+		{path: "total", tolerance: tolerance(0.01)},
+		{path: "lines", key: "sku"},
+		{path: "lines.#.price", tolerance: tolerance(0.001)},
+		{path: "lines.#.trace", exclude: true},
+		// the rules of the recursive type apply at any depth
+		{path: "shipments.*.**.tracked", exclude: true},
+	}, rules)
+
+	// the comparator of the types is cached
+	comparator := NewComparator(DefaultParser{})
+	_, err = comparator.Diff(taggedOrder{}, taggedOrder{})
+	assert.NoError(t, err)
+	cached, ok := comparator.tagged.Load([2]reflect.Type{reflect.TypeOf(taggedOrder{}), reflect.TypeOf(taggedOrder{})})
+	if assert.True(t, ok) {
+		assert.Contains(t, cached.(Comparator).exclude, "lines.#.trace")
+	}
+
+	type invalid struct {
+		Price float64 `compare:"tolerance=x"`
+	}
+	_, err = NewComparator(DefaultParser{}).Diff(invalid{}, invalid{})
+	assert.Error(t, err)
+}
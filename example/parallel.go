@@ -3,7 +3,6 @@ package example
 import (
 	"context"
 	"fmt"
-	"sync"
 
 	"github.com/EAFA0/Tool/parallel"
 )
@@ -20,38 +19,11 @@ func task(param Param) (Result, error) {
 
 // ParallelAction define a function for parallel actions.
 func ParallelAction(ctx context.Context, params []Param) ([]Result, error) {
-	results, store := make([]Result, 0, len(params)), sync.Map{}
+	line := parallel.NewLine[Param](2)
 
-	// wrapper task as a action
-	action := func(param Param) error {
-		// some multiple task
-		result, err := task(param)
-		store.Store(param, result)
-		return err
-	}
+	// do parallel action, the futures are in the order of params
+	futures := parallel.Async(ctx, &line, params, task)
 
-	// do parallel action and wait results
-	err := wrapperParallelAction(ctx, params, action)
-	store.Range(func(key, value interface{}) bool {
-		results = append(results, value.(Result))
-		return true
-	})
-
-	return results, err
-}
-
-// wrapperParallelAction
-func wrapperParallelAction(ctx context.Context, params []Param, action func(Param) error) error {
-	line, supplier := parallel.NewLine[Param](2), make(chan Param)
-
-	// wrapper action and execute.
-	line.Run(ctx, supplier, action)
-
-	// Pass parameters.
-	for _, param := range params {
-		supplier <- param
-	}
-	close(supplier)
-
-	return line.Wait()
+	// wait results
+	return parallel.All(futures...).Get(ctx)
 }
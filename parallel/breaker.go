@@ -0,0 +1,267 @@
+package parallel
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBreakerOpen is returned while the breaker rejects the calls.
+var ErrBreakerOpen = errors.New("circuit breaker is open")
+
+type BreakerState int
+
+const (
+	// BreakerClosed lets every call pass and counts the failures.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects every call until the open timeout elapsed.
+	BreakerOpen
+	// BreakerHalfOpen lets a few trial calls pass to probe the dependency.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// breakerBuckets is the number of buckets which the failure rate window is
+// divided into, the outdated buckets leave the window one by one.
+const breakerBuckets = 10
+
+// Breaker is a circuit breaker based on the failure rate of a rolling window.
+//
+// It opens when the failure rate of the window reaches the threshold, rejects
+// the calls for the open timeout, and then lets trial calls pass in half-open
+// state. It closes again when all the trial calls succeed, or opens again at
+// the first failed trial.
+type Breaker struct {
+	window      time.Duration
+	failureRate float64
+	minRequests int
+	openTimeout time.Duration
+	trials      int
+	clock       Clock
+
+	mu         sync.Mutex
+	state      BreakerState
+	generation uint64
+	openedAt   time.Time
+	buckets    [breakerBuckets]breakerBucket
+	// admitted and succeeded trial calls in half-open state
+	admitted, succeeded int
+}
+
+type breakerBucket struct {
+	start            time.Time
+	success, failure int
+}
+
+type BreakerOption func(*Breaker)
+
+// BreakerWindow sets the duration of the rolling window, default is 10s.
+func BreakerWindow(window time.Duration) BreakerOption {
+	return func(b *Breaker) {
+		b.window = window
+	}
+}
+
+// BreakerFailureRate opens the breaker when the failure rate in the window
+// reaches rate, and the window holds at least minRequests calls.
+// Default is 50% of 20 calls.
+func BreakerFailureRate(rate float64, minRequests int) BreakerOption {
+	return func(b *Breaker) {
+		b.failureRate, b.minRequests = rate, minRequests
+	}
+}
+
+// BreakerOpenTimeout sets how long the breaker stays open before it lets
+// trial calls pass, default is 5s.
+func BreakerOpenTimeout(timeout time.Duration) BreakerOption {
+	return func(b *Breaker) {
+		b.openTimeout = timeout
+	}
+}
+
+// BreakerTrials sets the number of trial calls in half-open state, default is 1.
+func BreakerTrials(trials int) BreakerOption {
+	return func(b *Breaker) {
+		b.trials = trials
+	}
+}
+
+// BreakerClock replaces the clock of the window and the open timeout.
+func BreakerClock(clock Clock) BreakerOption {
+	return func(b *Breaker) {
+		b.clock = clock
+	}
+}
+
+func NewBreaker(opts ...BreakerOption) *Breaker {
+	b := &Breaker{
+		window:      10 * time.Second,
+		failureRate: 0.5,
+		minRequests: 20,
+		openTimeout: 5 * time.Second,
+		trials:      1,
+		clock:       realClock{},
+	}
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		opt(b)
+	}
+	b.trials = max(b.trials, 1)
+	b.minRequests = max(b.minRequests, 1)
+	return b
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.current(b.clock.Now())
+}
+
+// Allow asks the breaker for a call, it returns ErrBreakerOpen if the call is
+// rejected, otherwise the outcome of the call must be reported through done.
+func (b *Breaker) Allow() (done func(success bool), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.current(b.clock.Now()) {
+	case BreakerOpen:
+		return nil, ErrBreakerOpen
+	case BreakerHalfOpen:
+		if b.admitted >= b.trials {
+			return nil, ErrBreakerOpen
+		}
+		b.admitted++
+	}
+
+	generation := b.generation
+	return func(success bool) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		// the outcome of a call from a former state is meaningless now
+		if generation == b.generation {
+			b.record(b.clock.Now(), success)
+		}
+	}, nil
+}
+
+// Do calls fn if the breaker allows, and records its outcome.
+func (b *Breaker) Do(fn func() error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	return observe(done, fn)
+}
+
+// observe calls fn and reports its outcome through done, a panic is a failure as well.
+func observe(done func(success bool), fn func() error) (err error) {
+	success := false
+	defer func() { done(success) }()
+
+	err = fn()
+	success = err == nil
+	return err
+}
+
+// current moves the breaker from open to half-open once the timeout elapsed.
+func (b *Breaker) current(now time.Time) BreakerState {
+	if b.state == BreakerOpen && now.Sub(b.openedAt) >= b.openTimeout {
+		b.shift(BreakerHalfOpen, now)
+	}
+	return b.state
+}
+
+func (b *Breaker) record(now time.Time, success bool) {
+	switch b.state {
+	case BreakerHalfOpen:
+		if !success {
+			b.shift(BreakerOpen, now)
+			return
+		}
+		if b.succeeded++; b.succeeded >= b.trials {
+			b.shift(BreakerClosed, now)
+		}
+	case BreakerClosed:
+		bucket := b.bucket(now)
+		if success {
+			bucket.success++
+			return
+		}
+		bucket.failure++
+
+		total, failure := b.count(now)
+		if total >= b.minRequests && float64(failure) >= b.failureRate*float64(total) {
+			b.shift(BreakerOpen, now)
+		}
+	}
+}
+
+func (b *Breaker) shift(state BreakerState, now time.Time) {
+	b.state, b.generation = state, b.generation+1
+	b.admitted, b.succeeded = 0, 0
+	b.buckets = [breakerBuckets]breakerBucket{}
+	if state == BreakerOpen {
+		b.openedAt = now
+	}
+}
+
+// bucket returns the bucket of now, and resets it if it is outdated.
+func (b *Breaker) bucket(now time.Time) *breakerBucket {
+	width := max(b.window/breakerBuckets, 1)
+	start := now.Truncate(width)
+	bucket := &b.buckets[(start.UnixNano()/int64(width))%breakerBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = breakerBucket{start: start}
+	}
+	return bucket
+}
+
+// count sums the calls of the buckets inside the window.
+func (b *Breaker) count(now time.Time) (total, failure int) {
+	for _, bucket := range b.buckets {
+		if now.Sub(bucket.start) < b.window {
+			total += bucket.success + bucket.failure
+			failure += bucket.failure
+		}
+	}
+	return
+}
+
+// LineBreaker guards the action of a Line with the breaker. The failed items
+// are routed to fallback instead of aborting the Line, so the breaker is able
+// to open during the run, and while it is open the items go to fallback without
+// calling the action. If fallback is nil the items fail fast, with
+// ErrBreakerOpen while the breaker is open.
+func LineBreaker[T any](b *Breaker, fallback func(T) error) LineOption[T] {
+	return func(line *Line[T]) {
+		line.middleware = append(line.middleware, func(action func(T) error) func(T) error {
+			return func(params T) error {
+				done, err := b.Allow()
+				if err == nil {
+					err = observe(done, func() error {
+						return action(params)
+					})
+				}
+				if err != nil && fallback != nil {
+					return fallback(params)
+				}
+				return err
+			}
+		})
+	}
+}
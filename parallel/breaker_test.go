@@ -0,0 +1,134 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreakerStates(t *testing.T) {
+	now := time.Unix(0, 0)
+	breaker := NewBreaker(
+		BreakerWindow(10*time.Second),
+		BreakerFailureRate(0.5, 4),
+		BreakerOpenTimeout(time.Second),
+		BreakerTrials(2),
+		BreakerClock(nowClock{now: &now}),
+	)
+	fail, pass := func() error { return errors.New("errmsg") }, func() error { return nil }
+
+	// 2 failures of 3 calls do not reach the min requests
+	assert.NoError(t, breaker.Do(pass))
+	assert.Error(t, breaker.Do(fail))
+	assert.Error(t, breaker.Do(fail))
+	assert.Equal(t, BreakerClosed, breaker.State())
+
+	assert.Error(t, breaker.Do(fail))
+	assert.Equal(t, BreakerOpen, breaker.State())
+	assert.ErrorIs(t, breaker.Do(pass), ErrBreakerOpen)
+
+	// a failed trial opens the breaker again
+	now = now.Add(time.Second)
+	assert.Equal(t, BreakerHalfOpen, breaker.State())
+	assert.Error(t, breaker.Do(fail))
+	assert.Equal(t, BreakerOpen, breaker.State())
+
+	// only the trials pass in half-open state
+	now = now.Add(time.Second)
+	first, err := breaker.Allow()
+	assert.NoError(t, err)
+	second, err := breaker.Allow()
+	assert.NoError(t, err)
+	_, err = breaker.Allow()
+	assert.ErrorIs(t, err, ErrBreakerOpen)
+
+	first(true)
+	assert.Equal(t, BreakerHalfOpen, breaker.State())
+	second(true)
+	assert.Equal(t, BreakerClosed, breaker.State())
+}
+
+func TestBreakerWindow(t *testing.T) {
+	now := time.Unix(0, 0)
+	breaker := NewBreaker(
+		BreakerWindow(10*time.Second),
+		BreakerFailureRate(0.6, 2),
+		BreakerClock(nowClock{now: &now}),
+	)
+
+	assert.Error(t, breaker.Do(func() error { return errors.New("errmsg") }))
+	// the first failure left the window, the rate is 1/2 instead of 2/3
+	now = now.Add(11 * time.Second)
+	assert.NoError(t, breaker.Do(func() error { return nil }))
+	assert.Error(t, breaker.Do(func() error { return errors.New("errmsg") }))
+	assert.Equal(t, BreakerClosed, breaker.State())
+
+	assert.Error(t, breaker.Do(func() error { return errors.New("errmsg") }))
+	assert.Equal(t, BreakerOpen, breaker.State())
+}
+
+func TestLineBreakerFallback(t *testing.T) {
+	breaker := NewBreaker(BreakerFailureRate(0.5, 1), BreakerOpenTimeout(time.Hour))
+	// open the breaker
+	assert.Error(t, breaker.Do(func() error { return errors.New("errmsg") }))
+
+	fallback := int32(0)
+	line, supplier := NewLine(2, LineBreaker(breaker, func(int) error {
+		atomic.AddInt32(&fallback, 1)
+		return nil
+	})), make(chan int)
+
+	line.Run(context.Background(), supplier, func(int) error {
+		return errors.New("should not be called")
+	})
+	for i := 0; i < 10; i++ {
+		supplier <- i
+	}
+	close(supplier)
+
+	assert.NoError(t, line.Wait())
+	assert.Equal(t, int32(10), atomic.LoadInt32(&fallback))
+}
+
+func TestLineBreakerFailFast(t *testing.T) {
+	breaker := NewBreaker(BreakerFailureRate(0.5, 1), BreakerOpenTimeout(time.Hour))
+	assert.Error(t, breaker.Do(func() error { return errors.New("errmsg") }))
+
+	line, supplier := NewLine(1, LineBreaker[int](breaker, nil)), make(chan int)
+	line.Run(context.Background(), supplier, func(int) error {
+		return nil
+	})
+	supplier <- 1
+	close(supplier)
+
+	assert.ErrorIs(t, line.Wait(), ErrBreakerOpen)
+}
+
+func TestLineBreakerOpenDuringRun(t *testing.T) {
+	breaker := NewBreaker(BreakerFailureRate(0.5, 3), BreakerOpenTimeout(time.Hour))
+
+	actions, fallback := int32(0), int32(0)
+	line, supplier := NewLine(1, LineBreaker(breaker, func(int) error {
+		atomic.AddInt32(&fallback, 1)
+		return nil
+	})), make(chan int)
+
+	line.Run(context.Background(), supplier, func(int) error {
+		atomic.AddInt32(&actions, 1)
+		return errors.New("errmsg")
+	})
+	for i := 0; i < 10; i++ {
+		supplier <- i
+	}
+	close(supplier)
+
+	// the failures open the breaker, the rest items skip the action
+	assert.NoError(t, line.Wait())
+	assert.Equal(t, int32(3), atomic.LoadInt32(&actions))
+	assert.Equal(t, int32(10), atomic.LoadInt32(&fallback))
+	assert.Equal(t, BreakerOpen, breaker.State())
+}
@@ -0,0 +1,130 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrBufferClosed is returned by Put after Close.
+var ErrBufferClosed = errors.New("buffer is closed")
+
+// Buffer is a supplier of Line which buffers the items by their total size
+// rather than their count, so the producers are not blocked by the workers
+// until the buffered items reach the limit.
+//
+// An item is buffered until the receiver of Supplier took it. An item larger
+// than the limit is buffered only when the buffer is empty.
+type Buffer[T any] struct {
+	limit int
+	size  func(T) int
+	out   chan T
+
+	mu     sync.Mutex
+	queue  []T
+	sizes  []int
+	used   int
+	closed bool
+	// changed is closed and replaced once the buffer changed
+	changed chan struct{}
+}
+
+// NewBuffer creates a buffer of limit bytes, size returns the bytes of an item.
+// The buffer must be closed by the caller, otherwise the supplier is never closed.
+func NewBuffer[T any](limit int, size func(T) int) *Buffer[T] {
+	b := &Buffer[T]{
+		limit:   limit,
+		size:    size,
+		out:     make(chan T),
+		changed: make(chan struct{}),
+	}
+	go b.forward()
+	return b
+}
+
+// Supplier returns the chan of the buffered items, which is closed once the
+// buffer is closed and the items are taken.
+func (b *Buffer[T]) Supplier() <-chan T {
+	return b.out
+}
+
+// Put buffers item, it blocks while the buffer has no room for item. It
+// returns ctx.Err() if ctx is done first.
+func (b *Buffer[T]) Put(ctx context.Context, item T) error {
+	n := b.size(item)
+	for {
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			return ErrBufferClosed
+		}
+		if b.used == 0 || b.used+n <= b.limit {
+			b.queue, b.sizes = append(b.queue, item), append(b.sizes, n)
+			b.used += n
+			b.notify()
+			b.mu.Unlock()
+			return nil
+		}
+		changed := b.changed
+		b.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Close stops accepting items, the buffered items are still supplied.
+func (b *Buffer[T]) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.closed {
+		b.closed = true
+		b.notify()
+	}
+}
+
+// Occupancy returns the count and the total size of the buffered items.
+func (b *Buffer[T]) Occupancy() (items, bytes int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.queue), b.used
+}
+
+// forward sends the buffered items to the supplier in order.
+func (b *Buffer[T]) forward() {
+	defer close(b.out)
+	for {
+		b.mu.Lock()
+		if len(b.queue) == 0 {
+			if b.closed {
+				b.mu.Unlock()
+				return
+			}
+			changed := b.changed
+			b.mu.Unlock()
+			<-changed
+			continue
+		}
+		item := b.queue[0]
+		b.mu.Unlock()
+
+		b.out <- item
+
+		b.mu.Lock()
+		var zero T
+		b.queue[0] = zero
+		b.used -= b.sizes[0]
+		b.queue, b.sizes = b.queue[1:], b.sizes[1:]
+		b.notify()
+		b.mu.Unlock()
+	}
+}
+
+// notify wakes the waiters, b.mu must be held.
+func (b *Buffer[T]) notify() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
@@ -0,0 +1,84 @@
+package parallel
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuffer(t *testing.T) {
+	buffer := NewBuffer(10, func(s string) int { return len(s) })
+	ctx := context.Background()
+
+	assert.NoError(t, buffer.Put(ctx, "aaaa"))
+	assert.NoError(t, buffer.Put(ctx, "bbbb"))
+	items, bytes := buffer.Occupancy()
+	assert.Equal(t, 2, items)
+	assert.Equal(t, 8, bytes)
+
+	// no room for the item until the first one is taken
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, buffer.Put(timeout, "ccc"), context.DeadlineExceeded)
+
+	put := make(chan error)
+	go func() {
+		put <- buffer.Put(ctx, "ccc")
+	}()
+	assert.Equal(t, "aaaa", <-buffer.Supplier())
+	assert.NoError(t, <-put)
+
+	assert.Eventually(t, func() bool {
+		items, bytes := buffer.Occupancy()
+		return items == 2 && bytes == 7
+	}, time.Second, time.Millisecond)
+
+	buffer.Close()
+	assert.ErrorIs(t, buffer.Put(ctx, "d"), ErrBufferClosed)
+
+	var rest []string
+	for item := range buffer.Supplier() {
+		rest = append(rest, item)
+	}
+	assert.Equal(t, []string{"bbbb", "ccc"}, rest)
+}
+
+func TestBufferLargeItem(t *testing.T) {
+	buffer := NewBuffer(2, func(s string) int { return len(s) })
+	defer buffer.Close()
+
+	// the item larger than the limit is buffered once the buffer is empty
+	assert.NoError(t, buffer.Put(context.Background(), "large"))
+	_, bytes := buffer.Occupancy()
+	assert.Equal(t, 5, bytes)
+	assert.Equal(t, "large", <-buffer.Supplier())
+}
+
+func TestBufferLine(t *testing.T) {
+	buffer := NewBuffer(64, func(b []byte) int { return len(b) })
+	line := NewLine[[]byte](2)
+
+	var (
+		mu    sync.Mutex
+		total int
+	)
+	line.Run(context.Background(), buffer.Supplier(), func(b []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		total += len(b)
+		return nil
+	})
+
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, buffer.Put(context.Background(), make([]byte, 16)))
+		_, bytes := buffer.Occupancy()
+		assert.LessOrEqual(t, bytes, 64)
+	}
+	buffer.Close()
+
+	assert.NoError(t, line.Wait())
+	assert.Equal(t, 1600, total)
+}
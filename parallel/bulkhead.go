@@ -0,0 +1,297 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrUnknownPool is the error of the items which are classified into a
+	// pool that does not exist.
+	ErrUnknownPool = errors.New("unknown pool")
+	// ErrBulkheadFull is the error of the items which are rejected because
+	// the queue of their pool is full, see BulkheadReject.
+	ErrBulkheadFull = errors.New("bulkhead pool is full")
+)
+
+// Bulkhead runs the items in several named pools, each of them is a Line with
+// its own concurrency, so a saturated pool does not starve the others.
+//
+// The dispatcher routes the items of the supplier into the pools by classify.
+// The items wait in the queue of their pool while it is saturated, and each
+// pool feeds its Line from its own queue, so the other pools keep receiving
+// their items. The queues are unbounded unless BulkheadQueue is set.
+type Bulkhead[T any] struct {
+	classify func(T) string
+	pools    map[string]*compartment[T]
+	done     chan struct{}
+
+	mu     sync.Mutex
+	anyErr error
+}
+
+// BulkheadStats is a snapshot of a pool.
+type BulkheadStats struct {
+	Size                   uint64
+	Queued, Running        int
+	Done, Failed, Rejected uint64
+}
+
+type compartment[T any] struct {
+	line     Line[T]
+	supplier chan T
+	// depth bounds the queued items, 0 means unbounded
+	depth  int
+	reject bool
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []T
+	closed bool
+	stats  BulkheadStats
+}
+
+type BulkheadOption[T any] func(*bulkheadConfig[T])
+
+type bulkheadConfig[T any] struct {
+	line   []LineOption[T]
+	depth  int
+	reject bool
+}
+
+// BulkheadLine applies opts to the Line of every pool.
+func BulkheadLine[T any](opts ...LineOption[T]) BulkheadOption[T] {
+	return func(c *bulkheadConfig[T]) {
+		c.line = append(c.line, opts...)
+	}
+}
+
+// BulkheadQueue bounds the queue of every pool by depth. The dispatcher waits
+// for room in the queue of a pool whose queue is full, unless BulkheadReject
+// is set.
+func BulkheadQueue[T any](depth int) BulkheadOption[T] {
+	return func(c *bulkheadConfig[T]) {
+		c.depth = max(depth, 1)
+	}
+}
+
+// BulkheadReject drops the items of a pool whose queue is full instead of
+// waiting, they are counted as rejected and Wait returns ErrBulkheadFull.
+func BulkheadReject[T any]() BulkheadOption[T] {
+	return func(c *bulkheadConfig[T]) {
+		c.reject = true
+	}
+}
+
+// NewBulkhead creates the pools by their name and size.
+func NewBulkhead[T any](pools map[string]uint64, classify func(T) string, opts ...BulkheadOption[T]) *Bulkhead[T] {
+	var config bulkheadConfig[T]
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		opt(&config)
+	}
+
+	b := &Bulkhead[T]{
+		classify: classify,
+		pools:    make(map[string]*compartment[T], len(pools)),
+		done:     make(chan struct{}),
+	}
+	for name, size := range pools {
+		c := &compartment[T]{
+			line:     NewLine(size, config.line...),
+			supplier: make(chan T),
+			depth:    config.depth,
+			reject:   config.reject && config.depth > 0,
+		}
+		c.cond = sync.NewCond(&c.mu)
+		c.stats.Size = size
+		c.line.middleware = append(c.line.middleware, c.track)
+		b.pools[name] = c
+	}
+	return b
+}
+
+// Run starts the pools and the dispatcher. The supplier must be closed by the
+// caller, otherwise Wait never returns.
+func (b *Bulkhead[T]) Run(ctx context.Context, supplier <-chan T, action func(T) error) {
+	// wake the dispatcher which waits for room in a queue
+	stop := context.AfterFunc(ctx, func() {
+		for _, c := range b.pools {
+			c.wake()
+		}
+	})
+
+	var wg sync.WaitGroup
+	for _, c := range b.pools {
+		c.line.Run(ctx, c.supplier, action)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.feed()
+		}()
+	}
+
+	go func() {
+		defer func() {
+			stop()
+			for _, c := range b.pools {
+				c.close()
+			}
+			wg.Wait()
+			for _, c := range b.pools {
+				c.line.Wait()
+			}
+			close(b.done)
+		}()
+
+		for params := range supplier {
+			if err := ctx.Err(); err != nil {
+				b.setErr(err)
+				dropChan(supplier)
+				return
+			}
+
+			name := b.classify(params)
+			c, ok := b.pools[name]
+			if !ok {
+				b.setErr(fmt.Errorf("%w: %s", ErrUnknownPool, name))
+				continue
+			}
+			if err := c.push(ctx, params); errors.Is(err, ErrBulkheadFull) {
+				b.setErr(fmt.Errorf("%w: %s", err, name))
+			} else if err != nil {
+				b.setErr(err)
+				dropChan(supplier)
+				return
+			}
+		}
+	}()
+}
+
+// Wait until all the pools finished, the error joins the errors of the pools
+// and the dispatcher.
+func (b *Bulkhead[T]) Wait() error {
+	<-b.done
+
+	names := make([]string, 0, len(b.pools))
+	for name := range b.pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b.mu.Lock()
+	errs := []error{b.anyErr}
+	b.mu.Unlock()
+	for _, name := range names {
+		if err := b.pools[name].line.err(); err != nil {
+			errs = append(errs, fmt.Errorf("pool %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Stats returns the snapshots of the pools by their name.
+func (b *Bulkhead[T]) Stats() map[string]BulkheadStats {
+	stats := make(map[string]BulkheadStats, len(b.pools))
+	for name, c := range b.pools {
+		c.mu.Lock()
+		stats[name] = c.stats
+		c.mu.Unlock()
+	}
+	return stats
+}
+
+func (b *Bulkhead[T]) setErr(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.anyErr == nil {
+		b.anyErr = err
+	}
+}
+
+// push puts the item into the queue, it waits for room while the queue is
+// full, or rejects the item with ErrBulkheadFull.
+func (c *compartment[T]) push(ctx context.Context, params T) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.depth > 0 && c.stats.Queued >= c.depth {
+		if c.reject {
+			c.stats.Rejected++
+			return ErrBulkheadFull
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c.cond.Wait()
+	}
+	c.queue = append(c.queue, params)
+	c.stats.Queued++
+	c.cond.Broadcast()
+	return nil
+}
+
+func (c *compartment[T]) wake() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cond.Broadcast()
+}
+
+func (c *compartment[T]) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	c.cond.Broadcast()
+}
+
+// feed sends the queued items to the line of the pool, and releases their
+// place in the queue once the line took them.
+func (c *compartment[T]) feed() {
+	defer close(c.supplier)
+	for {
+		c.mu.Lock()
+		for len(c.queue) == 0 && !c.closed {
+			c.cond.Wait()
+		}
+		if len(c.queue) == 0 {
+			c.mu.Unlock()
+			return
+		}
+		params := c.queue[0]
+		c.queue = c.queue[1:]
+		c.mu.Unlock()
+
+		c.supplier <- params
+
+		c.mu.Lock()
+		c.stats.Queued--
+		c.cond.Broadcast()
+		c.mu.Unlock()
+	}
+}
+
+// track is the middleware which counts the items of the pool.
+func (c *compartment[T]) track(action func(T) error) func(T) error {
+	return func(params T) error {
+		c.mu.Lock()
+		c.stats.Running++
+		c.mu.Unlock()
+
+		return observe(func(success bool) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.stats.Running--
+			if success {
+				c.stats.Done++
+			} else {
+				c.stats.Failed++
+			}
+		}, func() error {
+			return action(params)
+		})
+	}
+}
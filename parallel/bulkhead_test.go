@@ -0,0 +1,188 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type request struct {
+	kind string
+	id   int
+}
+
+func TestBulkhead(t *testing.T) {
+	bulkhead := NewBulkhead(map[string]uint64{"db": 1, "http": 2}, func(r request) string {
+		return r.kind
+	})
+	supplier, release := make(chan request), make(chan struct{})
+
+	httpDone := make(chan struct{}, 10)
+	bulkhead.Run(context.Background(), supplier, func(r request) error {
+		if r.kind == "db" {
+			<-release
+			return nil
+		}
+		httpDone <- struct{}{}
+		return nil
+	})
+
+	// the saturated db pool does not block the http pool
+	supplier <- request{kind: "db"}
+	assert.Eventually(t, func() bool {
+		return bulkhead.Stats()["db"].Running == 1
+	}, time.Second, time.Millisecond)
+	supplier <- request{kind: "db"}
+	for i := 0; i < 5; i++ {
+		supplier <- request{kind: "http", id: i}
+		<-httpDone
+	}
+
+	assert.Eventually(t, func() bool {
+		stats := bulkhead.Stats()
+		return stats["http"] == BulkheadStats{Size: 2, Done: 5} &&
+			stats["db"] == BulkheadStats{Size: 1, Queued: 1, Running: 1}
+	}, time.Second, time.Millisecond)
+
+	close(release)
+	close(supplier)
+	assert.NoError(t, bulkhead.Wait())
+	assert.Equal(t, BulkheadStats{Size: 1, Done: 2}, bulkhead.Stats()["db"])
+}
+
+func TestBulkheadError(t *testing.T) {
+	bulkhead := NewBulkhead(map[string]uint64{"db": 1, "http": 1}, func(r request) string {
+		return r.kind
+	})
+	supplier := make(chan request)
+
+	bulkhead.Run(context.Background(), supplier, func(r request) error {
+		if r.kind == "db" {
+			return errors.New("errmsg")
+		}
+		return nil
+	})
+	supplier <- request{kind: "db"}
+	supplier <- request{kind: "cache"}
+	supplier <- request{kind: "http"}
+	close(supplier)
+
+	err := bulkhead.Wait()
+	assert.ErrorIs(t, err, ErrUnknownPool)
+	assert.Contains(t, err.Error(), "pool db: errmsg")
+	assert.Equal(t, uint64(1), bulkhead.Stats()["http"].Done)
+}
+
+func TestBulkheadCancel(t *testing.T) {
+	bulkhead := NewBulkhead(map[string]uint64{"db": 1}, func(r request) string {
+		return r.kind
+	})
+	supplier, release := make(chan request), make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	bulkhead.Run(ctx, supplier, func(r request) error {
+		<-release
+		return nil
+	})
+	supplier <- request{kind: "db"}
+
+	// the items after the cancellation are dropped
+	cancel()
+	supplier <- request{kind: "db"}
+	close(release)
+	close(supplier)
+	assert.ErrorIs(t, bulkhead.Wait(), context.Canceled)
+}
+
+func TestBulkheadIsolation(t *testing.T) {
+	bulkhead := NewBulkhead(map[string]uint64{"db": 1, "http": 1}, func(r request) string {
+		return r.kind
+	})
+	supplier, release := make(chan request), make(chan struct{})
+
+	httpDone := make(chan struct{})
+	bulkhead.Run(context.Background(), supplier, func(r request) error {
+		if r.kind == "db" {
+			<-release
+			return nil
+		}
+		close(httpDone)
+		return nil
+	})
+
+	// the db items are kept in the queue of the db pool
+	for i := 0; i < 5; i++ {
+		supplier <- request{kind: "db", id: i}
+	}
+	supplier <- request{kind: "http"}
+
+	select {
+	case <-httpDone:
+	case <-time.After(time.Second):
+		t.Fatal("the http pool is blocked by the db pool")
+	}
+
+	close(release)
+	close(supplier)
+	assert.NoError(t, bulkhead.Wait())
+	assert.Equal(t, BulkheadStats{Size: 1, Done: 5}, bulkhead.Stats()["db"])
+}
+
+func TestBulkheadQueue(t *testing.T) {
+	bulkhead := NewBulkhead(map[string]uint64{"db": 1}, func(r request) string {
+		return r.kind
+	}, BulkheadQueue[request](1))
+	supplier, release := make(chan request), make(chan struct{})
+
+	bulkhead.Run(context.Background(), supplier, func(r request) error {
+		<-release
+		return nil
+	})
+	supplier <- request{kind: "db"}
+	supplier <- request{kind: "db"}
+
+	// the dispatcher waits for room in the full queue
+	sent := make(chan struct{})
+	go func() {
+		supplier <- request{kind: "db"}
+		supplier <- request{kind: "db"}
+		close(sent)
+	}()
+	select {
+	case <-sent:
+		t.Fatal("the items are not kept in the bounded queue")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(release)
+	<-sent
+	close(supplier)
+	assert.NoError(t, bulkhead.Wait())
+	assert.Equal(t, uint64(4), bulkhead.Stats()["db"].Done)
+}
+
+func TestBulkheadReject(t *testing.T) {
+	bulkhead := NewBulkhead(map[string]uint64{"db": 1}, func(r request) string {
+		return r.kind
+	}, BulkheadQueue[request](1), BulkheadReject[request]())
+	supplier, release := make(chan request), make(chan struct{})
+
+	bulkhead.Run(context.Background(), supplier, func(r request) error {
+		<-release
+		return nil
+	})
+	for i := 0; i < 5; i++ {
+		supplier <- request{kind: "db", id: i}
+	}
+	close(release)
+	close(supplier)
+
+	// at most one item runs and one is queued
+	assert.ErrorIs(t, bulkhead.Wait(), ErrBulkheadFull)
+	stats := bulkhead.Stats()["db"]
+	assert.Equal(t, uint64(5), stats.Done+stats.Rejected)
+	assert.GreaterOrEqual(t, stats.Rejected, uint64(3))
+}
@@ -0,0 +1,19 @@
+package parallel
+
+import "time"
+
+// Clock is the source of time, it is replaced by a fake one in testing.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
@@ -0,0 +1,63 @@
+package parallel_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/EAFA0/Tool/parallel"
+	"github.com/EAFA0/Tool/parallel/paralleltest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLineDeadline(t *testing.T) {
+	clock := paralleltest.NewClock(time.Unix(0, 0))
+	line := parallel.NewLine(2, parallel.LineDeadline[int](time.Second), parallel.LineClock[int](clock))
+	supplier, started, canceled := make(chan int), make(chan struct{}), make(chan struct{})
+
+	line.RunContext(context.Background(), supplier, func(ctx context.Context, params int) error {
+		if params < 2 {
+			started <- struct{}{}
+			return nil
+		}
+		// the slow item is canceled at the deadline
+		started <- struct{}{}
+		<-ctx.Done()
+		close(canceled)
+		return context.Cause(ctx)
+	})
+
+	for i := 0; i < 3; i++ {
+		supplier <- i
+		<-started
+	}
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	<-canceled
+
+	// no more params are started
+	for i := 3; i < 6; i++ {
+		supplier <- i
+	}
+	close(supplier)
+
+	report, err := line.WaitReport()
+	assert.ErrorIs(t, err, parallel.ErrDeadline)
+	assert.Equal(t, parallel.Report{Started: 3, Done: 2, Failed: 1, Skipped: 3, Elapsed: time.Second, Expired: true}, report)
+}
+
+func TestLineReport(t *testing.T) {
+	line, supplier := parallel.NewLine[int](2, parallel.LineDeadline[int](time.Hour)), make(chan int)
+	line.Run(context.Background(), supplier, func(int) error {
+		return nil
+	})
+	for i := 0; i < 4; i++ {
+		supplier <- i
+	}
+	close(supplier)
+
+	report, err := line.WaitReport()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), report.Done)
+	assert.False(t, report.Expired)
+}
@@ -0,0 +1,105 @@
+package parallel
+
+import (
+	"context"
+	"sync"
+)
+
+type ExpandOption func(*expandConfig)
+
+type expandConfig struct {
+	depth int
+}
+
+// ExpandDepth limits the depth of the items, the roots are at depth 0 and the
+// follow-up items deeper than depth are dropped. Default is unlimited.
+func ExpandDepth(depth int) ExpandOption {
+	return func(c *expandConfig) {
+		c.depth = depth
+	}
+}
+
+// expansion is the state of the item of a key.
+type expansion[T any] struct {
+	// depth is the smallest depth which the item is reached at
+	depth int
+	done  bool
+	// next keeps the follow-up items to grow them again, once the item is
+	// reached at a smaller depth after its action finished
+	next []T
+}
+
+// Expand runs action over roots on the line, the follow-up items returned by
+// action are run on the same line, e.g. crawling a directory tree. The items
+// are run once for each key, an item which is reached again at a smaller
+// depth grows its follow-up items again without running. The line finishes by
+// itself once no items are left, so it is waited by line.Wait as usual.
+func Expand[T any, K comparable](ctx context.Context, line *StealLine[T], roots []T, key func(T) K, action func(T) ([]T, error), opts ...ExpandOption) {
+	config := expandConfig{depth: -1}
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		opt(&config)
+	}
+
+	var mu sync.Mutex
+	states := make(map[K]*expansion[T])
+	// reach records the item at depth, it reports whether the item should
+	// run, or returns the follow-up items to grow again
+	reach := func(item T, depth int) (bool, []T) {
+		mu.Lock()
+		defer mu.Unlock()
+		state, ok := states[key(item)]
+		if !ok {
+			states[key(item)] = &expansion[T]{depth: depth}
+			return true, nil
+		}
+		if depth >= state.depth {
+			return false, nil
+		}
+		state.depth = depth
+		if !state.done {
+			// the running action grows its items from the new depth
+			return false, nil
+		}
+		return false, state.next
+	}
+
+	var grow func(next []T, depth int, spawn func(T))
+	grow = func(next []T, depth int, spawn func(T)) {
+		if config.depth >= 0 && depth > config.depth {
+			return
+		}
+		for _, item := range next {
+			if run, again := reach(item, depth); run {
+				spawn(item)
+			} else {
+				grow(again, depth+1, spawn)
+			}
+		}
+	}
+
+	items := make([]T, 0, len(roots))
+	for _, root := range roots {
+		if run, _ := reach(root, 0); run {
+			items = append(items, root)
+		}
+	}
+
+	line.Run(ctx, items, func(item T, spawn func(T)) error {
+		next, err := action(item)
+
+		mu.Lock()
+		state := states[key(item)]
+		state.done = true
+		if config.depth >= 0 {
+			state.next = next
+		}
+		depth := state.depth
+		mu.Unlock()
+
+		grow(next, depth+1, spawn)
+		return err
+	})
+}
@@ -0,0 +1,73 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// graph is a directed graph with cycles.
+var graph = map[string][]string{
+	"a": {"b", "c"},
+	"b": {"c", "d"},
+	"c": {"a", "e"},
+	"d": {"e"},
+	"e": {"f"},
+	"f": {},
+}
+
+func crawl(t *testing.T, opts ...ExpandOption) []string {
+	line, mu, seen := NewStealLine[string](3), sync.Mutex{}, []string{}
+	Expand(context.Background(), &line, []string{"a", "a"}, func(s string) string { return s }, func(item string) ([]string, error) {
+		mu.Lock()
+		seen = append(seen, item)
+		mu.Unlock()
+		return graph[item], nil
+	}, opts...)
+
+	assert.NoError(t, line.Wait())
+	sort.Strings(seen)
+	return seen
+}
+
+func TestExpand(t *testing.T) {
+	assert.Equal(t, []string{"a", "b", "c", "d", "e", "f"}, crawl(t))
+}
+
+func TestExpandDepth(t *testing.T) {
+	assert.Equal(t, []string{"a"}, crawl(t, ExpandDepth(0)))
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, crawl(t, ExpandDepth(2)))
+}
+
+func TestExpandError(t *testing.T) {
+	line := NewStealLine[string](2)
+	Expand(context.Background(), &line, []string{"a"}, func(s string) string { return s }, func(item string) ([]string, error) {
+		if item == "d" {
+			return nil, errors.New("errmsg")
+		}
+		return graph[item], nil
+	})
+	assert.EqualError(t, line.Wait(), "errmsg")
+}
+
+func TestExpandShallower(t *testing.T) {
+	// c is reached at depth 2 through b and x, and at depth 1 through a
+	graph := map[string][]string{"a": {"c"}, "b": {"x"}, "x": {"c"}, "c": {"d"}}
+	for i := 0; i < 20; i++ {
+		line, mu, seen := NewStealLine[string](1), sync.Mutex{}, []string{}
+		Expand(context.Background(), &line, []string{"a", "b"}, func(s string) string { return s }, func(item string) ([]string, error) {
+			mu.Lock()
+			seen = append(seen, item)
+			mu.Unlock()
+			return graph[item], nil
+		}, ExpandDepth(2))
+
+		assert.NoError(t, line.Wait())
+		sort.Strings(seen)
+		assert.Equal(t, []string{"a", "b", "c", "d", "x"}, seen)
+	}
+}
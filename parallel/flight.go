@@ -0,0 +1,145 @@
+package parallel
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Flight deduplicates concurrent calls for the same key, the callers which
+// arrive while a call is in flight share its result instead of calling again.
+type Flight[K comparable, V any] struct {
+	mu    sync.Mutex
+	calls map[K]*flightCall[V]
+	// the expired results are swept at most once per expire duration
+	sweepAt time.Time
+
+	flightConfig
+}
+
+type flightConfig struct {
+	expire time.Duration
+	clock  Clock
+}
+
+type flightCall[V any] struct {
+	done chan struct{}
+	val  V
+	err  error
+	// deadline of the result, zero value means the call is still in flight.
+	deadline time.Time
+	// dups counts the callers which share the call
+	dups int
+}
+
+type FlightOption func(*flightConfig)
+
+// FlightExpire keeps a successful result for the duration after the call
+// finished, so later callers reuse it without calling again.
+// Errors are never kept.
+func FlightExpire(expire time.Duration) FlightOption {
+	return func(c *flightConfig) {
+		c.expire = expire
+	}
+}
+
+// FlightClock replaces the clock of the expiry.
+func FlightClock(clock Clock) FlightOption {
+	return func(c *flightConfig) {
+		c.clock = clock
+	}
+}
+
+func NewFlight[K comparable, V any](opts ...FlightOption) *Flight[K, V] {
+	f := &Flight[K, V]{
+		calls:        make(map[K]*flightCall[V]),
+		flightConfig: flightConfig{clock: realClock{}},
+	}
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		opt(&f.flightConfig)
+	}
+	return f
+}
+
+// Do calls fn once for all the concurrent callers of the key and returns its
+// result, shared reports whether the result was produced for another caller.
+//
+// fn runs with a context which is detached from the cancellation of ctx, when
+// ctx is done Do returns ctx.Err() at once but the shared call goes on for the
+// other callers.
+func (f *Flight[K, V]) Do(ctx context.Context, key K, fn func(context.Context) (V, error)) (val V, shared bool, err error) {
+	f.mu.Lock()
+	now := f.clock.Now()
+	f.sweep(now)
+	call, ok := f.calls[key]
+	if ok && call.expired(now) {
+		delete(f.calls, key)
+		ok = false
+	}
+	if ok {
+		call.dups++
+	} else {
+		call = &flightCall[V]{done: make(chan struct{})}
+		f.calls[key] = call
+		go f.call(context.WithoutCancel(ctx), key, call, fn)
+	}
+	f.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.val, ok, call.err
+	case <-ctx.Done():
+		return val, ok, ctx.Err()
+	}
+}
+
+// Forget drops the result or the in flight call of the key, the next call of
+// Do for the key calls fn again. The callers waiting for a forgotten call
+// still receive its result.
+func (f *Flight[K, V]) Forget(key K) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.calls, key)
+}
+
+// sweep drops the expired results of all keys, so the results of the keys
+// which are never asked again do not pile up.
+func (f *Flight[K, V]) sweep(now time.Time) {
+	if f.expire <= 0 || now.Before(f.sweepAt) {
+		return
+	}
+	for key, call := range f.calls {
+		if call.expired(now) {
+			delete(f.calls, key)
+		}
+	}
+	f.sweepAt = now.Add(f.expire)
+}
+
+func (c *flightCall[V]) expired(now time.Time) bool {
+	return !c.deadline.IsZero() && !now.Before(c.deadline)
+}
+
+func (f *Flight[K, V]) call(ctx context.Context, key K, call *flightCall[V], fn func(context.Context) (V, error)) {
+	defer func() {
+		if msg := recover(); msg != nil {
+			call.err = recovered(msg)
+		}
+
+		f.mu.Lock()
+		if f.calls[key] == call {
+			if call.err != nil || f.expire <= 0 {
+				delete(f.calls, key)
+			} else {
+				call.deadline = f.clock.Now().Add(f.expire)
+			}
+		}
+		f.mu.Unlock()
+		close(call.done)
+	}()
+
+	call.val, call.err = fn(ctx)
+}
@@ -0,0 +1,147 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// nowClock is a Clock whose time is set by the test.
+type nowClock struct {
+	realClock
+	now *time.Time
+}
+
+func (c nowClock) Now() time.Time {
+	return *c.now
+}
+
+func TestFlightShared(t *testing.T) {
+	flight, calls := NewFlight[string, int](), int32(0)
+	release := make(chan struct{})
+
+	fn := func(context.Context) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return 1, nil
+	}
+	var wg sync.WaitGroup
+	results := make([]int, 5)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			val, _, err := flight.Do(context.Background(), "key", fn)
+			assert.NoError(t, err)
+			results[i] = val
+		}(i)
+	}
+
+	// wait until all the callers share the call
+	for flightDups(flight, "key") < len(results)-1 {
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(t, []int{1, 1, 1, 1, 1}, results)
+}
+
+func TestFlightAbandon(t *testing.T) {
+	flight, release := NewFlight[string, int](), make(chan struct{})
+	fn := func(ctx context.Context) (int, error) {
+		<-release
+		return 1, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err := flight.Do(ctx, "key", fn)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// the shared call is not canceled by the first caller
+	go func() {
+		for flightDups(flight, "key") < 1 {
+			runtime.Gosched()
+		}
+		close(release)
+	}()
+	val, shared, err := flight.Do(context.Background(), "key", fn)
+	assert.NoError(t, err)
+	assert.True(t, shared)
+	assert.Equal(t, 1, val)
+}
+
+func TestFlightExpire(t *testing.T) {
+	now := time.Unix(0, 0)
+	flight := NewFlight[string, int](FlightExpire(time.Second), FlightClock(nowClock{now: &now}))
+
+	calls := 0
+	fn := func(context.Context) (int, error) {
+		calls++
+		return calls, nil
+	}
+
+	val, _, _ := flight.Do(context.Background(), "key", fn)
+	assert.Equal(t, 1, val)
+	val, shared, _ := flight.Do(context.Background(), "key", fn)
+	assert.Equal(t, 1, val)
+	assert.True(t, shared)
+
+	now = now.Add(time.Second)
+	val, _, _ = flight.Do(context.Background(), "key", fn)
+	assert.Equal(t, 2, val)
+
+	flight.Forget("key")
+	val, _, _ = flight.Do(context.Background(), "key", fn)
+	assert.Equal(t, 3, val)
+}
+
+func TestFlightError(t *testing.T) {
+	flight := NewFlight[string, int](FlightExpire(time.Hour))
+
+	_, _, err := flight.Do(context.Background(), "key", func(context.Context) (int, error) {
+		return 0, errors.New("errmsg")
+	})
+	assert.EqualError(t, err, "errmsg")
+
+	// errors are not kept
+	_, _, err = flight.Do(context.Background(), "key", func(context.Context) (int, error) {
+		panic("panic msg")
+	})
+	assert.EqualError(t, err, "panic msg")
+}
+
+func TestFlightSweep(t *testing.T) {
+	now := time.Unix(0, 0)
+	flight := NewFlight[int, int](FlightExpire(time.Second), FlightClock(nowClock{now: &now}))
+
+	fn := func(context.Context) (int, error) { return 1, nil }
+	for i := 0; i < 100; i++ {
+		_, _, err := flight.Do(context.Background(), i, fn)
+		assert.NoError(t, err)
+		now = now.Add(100 * time.Millisecond)
+	}
+
+	// the results of the keys never asked again are swept
+	flight.mu.Lock()
+	defer flight.mu.Unlock()
+	assert.LessOrEqual(t, len(flight.calls), 20)
+}
+
+// flightDups returns the number of the callers which share the call of the key.
+func flightDups[K comparable, V any](flight *Flight[K, V], key K) int {
+	flight.mu.Lock()
+	defer flight.mu.Unlock()
+	if call, ok := flight.calls[key]; ok {
+		return call.dups
+	}
+	return 0
+}
@@ -0,0 +1,199 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Future is the result of an asynchronous task, which is available once the
+// task finished.
+type Future[T any] struct {
+	once sync.Once
+	done chan struct{}
+	val  T
+	err  error
+}
+
+func newFuture[T any]() *Future[T] {
+	return &Future[T]{done: make(chan struct{})}
+}
+
+// resolve sets the result, the later calls are ignored.
+func (f *Future[T]) resolve(val T, err error) {
+	f.once.Do(func() {
+		f.val, f.err = val, err
+		close(f.done)
+	})
+}
+
+// Done is closed when the result is available.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Get waits for the result, it returns ctx.Err() if ctx is done first.
+func (f *Future[T]) Get(ctx context.Context) (val T, err error) {
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		return val, ctx.Err()
+	}
+}
+
+// Settled is the result of a future, which is collected by AllSettled.
+type Settled[T any] struct {
+	Val T
+	Err error
+}
+
+// Then calls fn with the value of f once it is available, and returns the
+// future of its result. fn is skipped if f failed, its error is passed on.
+func Then[T, R any](f *Future[T], fn func(T) (R, error)) *Future[R] {
+	next := newFuture[R]()
+	go func() {
+		var (
+			val R
+			err error
+		)
+		defer func() {
+			if msg := recover(); msg != nil {
+				err = recovered(msg)
+			}
+			next.resolve(val, err)
+		}()
+
+		<-f.done
+		if err = f.err; err == nil {
+			val, err = fn(f.val)
+		}
+	}()
+	return next
+}
+
+// All returns the future of all the values in order, it fails as soon as one
+// of the futures failed.
+func All[T any](futures ...*Future[T]) *Future[[]T] {
+	all := newFuture[[]T]()
+	go func() {
+		vals := make([]T, len(futures))
+		for settled := range settle(futures) {
+			if settled.Err != nil {
+				all.resolve(nil, settled.Err)
+				return
+			}
+			vals[settled.index] = settled.Val
+		}
+		all.resolve(vals, nil)
+	}()
+	return all
+}
+
+// Any returns the future of the first successful value, it fails with the
+// joined errors if all of the futures failed.
+func Any[T any](futures ...*Future[T]) *Future[T] {
+	first := newFuture[T]()
+	go func() {
+		errs := make([]error, len(futures))
+		for settled := range settle(futures) {
+			if settled.Err == nil {
+				first.resolve(settled.Val, nil)
+				return
+			}
+			errs[settled.index] = settled.Err
+		}
+
+		var val T
+		if len(futures) == 0 {
+			errs = append(errs, errors.New("no futures"))
+		}
+		first.resolve(val, errors.Join(errs...))
+	}()
+	return first
+}
+
+// AllSettled returns the future of all the results in order, it waits for
+// every future and never fails.
+func AllSettled[T any](futures ...*Future[T]) *Future[[]Settled[T]] {
+	all := newFuture[[]Settled[T]]()
+	go func() {
+		results := make([]Settled[T], len(futures))
+		for settled := range settle(futures) {
+			results[settled.index] = settled.Settled
+		}
+		all.resolve(results, nil)
+	}()
+	return all
+}
+
+type indexed[T any] struct {
+	Settled[T]
+	index int
+}
+
+// settle sends the results of futures in the order they are available, the
+// chan is closed after all of them are sent.
+func settle[T any](futures []*Future[T]) <-chan indexed[T] {
+	// buffered, so the senders never block when the receiver returns early
+	results := make(chan indexed[T], len(futures))
+	var wg sync.WaitGroup
+	wg.Add(len(futures))
+	for i, f := range futures {
+		go func() {
+			defer wg.Done()
+			<-f.done
+			results <- indexed[T]{Settled: Settled[T]{Val: f.val, Err: f.err}, index: i}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// ErrSkipped is held by the futures whose params are skipped by the line
+// without an error, e.g. routed to the fallback of LineBreaker.
+var ErrSkipped = errors.New("params skipped")
+
+// Async runs fn over params on the line, and returns the futures of the
+// results in the order of params. It takes the place of Run, so line must not
+// be run elsewhere, but line.Wait works as usual.
+//
+// The line is stopped by the first error the same as Run, the futures of the
+// params which are not run then hold the error of the line.
+func Async[T, R any](ctx context.Context, line *Line[T], params []T, fn func(T) (R, error)) []*Future[R] {
+	futures := make([]*Future[R], len(params))
+	for i := range futures {
+		futures[i] = newFuture[R]()
+	}
+
+	// run the indexes of params, the state is shared with line
+	indexes, supplier := share(line, func(i int) T { return params[i] }), make(chan int)
+	indexes.Run(ctx, supplier, func(i int) error {
+		return line.wrap(func(param T) error {
+			val, err := fn(param)
+			futures[i].resolve(val, err)
+			return err
+		})(params[i])
+	})
+
+	go func() {
+		for i := range params {
+			supplier <- i
+		}
+		close(supplier)
+
+		<-line.done
+		err := line.err()
+		if err == nil {
+			err = ErrSkipped
+		}
+		for _, future := range futures {
+			future.resolve(*new(R), err)
+		}
+	}()
+	return futures
+}
@@ -0,0 +1,110 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func resolved[T any](val T, err error) *Future[T] {
+	future := newFuture[T]()
+	future.resolve(val, err)
+	return future
+}
+
+func TestFutureGet(t *testing.T) {
+	future := newFuture[int]()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err := future.Get(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	future.resolve(1, nil)
+	future.resolve(2, nil)
+	val, err := future.Get(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, val)
+}
+
+func TestThen(t *testing.T) {
+	val, err := Then(resolved(1, nil), func(val int) (string, error) {
+		return strconv.Itoa(val + 1), nil
+	}).Get(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "2", val)
+
+	_, err = Then(resolved(1, errors.New("errmsg")), func(val int) (string, error) {
+		panic("should not be called")
+	}).Get(context.Background())
+	assert.EqualError(t, err, "errmsg")
+
+	_, err = Then(resolved(1, nil), func(val int) (string, error) {
+		panic("panic msg")
+	}).Get(context.Background())
+	assert.EqualError(t, err, "panic msg")
+}
+
+func TestAll(t *testing.T) {
+	vals, err := All(resolved(1, nil), resolved(2, nil)).Get(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, vals)
+
+	// fail fast without waiting for the pending future
+	_, err = All(newFuture[int](), resolved(2, errors.New("errmsg"))).Get(context.Background())
+	assert.EqualError(t, err, "errmsg")
+}
+
+func TestAny(t *testing.T) {
+	val, err := Any(newFuture[int](), resolved(0, errors.New("errmsg")), resolved(3, nil)).Get(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, val)
+
+	first, second := errors.New("first"), errors.New("second")
+	_, err = Any(resolved(0, first), resolved(0, second)).Get(context.Background())
+	assert.ErrorIs(t, err, first)
+	assert.ErrorIs(t, err, second)
+
+	_, err = Any[int]().Get(context.Background())
+	assert.Error(t, err)
+}
+
+func TestAllSettled(t *testing.T) {
+	errmsg := errors.New("errmsg")
+	results, err := AllSettled(resolved(1, nil), resolved(0, errmsg)).Get(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []Settled[int]{{Val: 1}, {Err: errmsg}}, results)
+}
+
+func TestAsync(t *testing.T) {
+	line := NewLine[int](2)
+	futures := Async(context.Background(), &line, []int{1, 2, 3}, func(param int) (string, error) {
+		return strconv.Itoa(param), nil
+	})
+
+	vals, err := All(futures...).Get(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "2", "3"}, vals)
+	assert.NoError(t, line.Wait())
+}
+
+func TestAsyncError(t *testing.T) {
+	line := NewLine[int](1)
+	futures := Async(context.Background(), &line, []int{1, 2, 3}, func(param int) (int, error) {
+		if param == 2 {
+			panic("panic msg")
+		}
+		return param, nil
+	})
+
+	results, _ := AllSettled(futures...).Get(context.Background())
+	assert.Equal(t, Settled[int]{Val: 1}, results[0])
+	// the panic and the params after it hold the error of the line
+	assert.EqualError(t, results[1].Err, "panic msg")
+	assert.EqualError(t, results[2].Err, "panic msg")
+	assert.EqualError(t, line.Wait(), "panic msg")
+}
@@ -4,91 +4,332 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-type Line struct {
+// ErrDeadline is the error of a Line which spent its budget of LineDeadline.
+var ErrDeadline = errors.New("line deadline exceeded")
+
+// Line runs an action over every item of a supplier with a fixed number of
+// workers, and stops at the first panic, error or cancellation.
+type Line[T any] struct {
 	size   uint64
+	cancel <-chan struct{}
+	// middleware wraps the action, the first one is the outermost.
+	middleware []func(func(T) error) func(T) error
+	scheduler  Scheduler
+	clock      Clock
+	// hooks are called when the line starts to run.
+	hooks  []func()
+	budget time.Duration
+	log    *lineLog[T]
+
+	*lineState
+}
+
+// lineState is shared by the copies of a Line.
+type lineState struct {
+	wg     sync.WaitGroup
 	done   chan struct{}
+	mu     sync.Mutex
 	anyErr error
-	cancel <-chan struct{}
+
+	begin, end                          time.Time
+	started, succeeded, failed, skipped atomic.Uint64
+	expired                             atomic.Bool
+}
+
+// Report counts the params of a Line, it is complete once the line finished.
+type Report struct {
+	// Started params are done or failed, unless the line is running.
+	Started, Done, Failed uint64
+	// Skipped params are dropped without running, after the line stopped.
+	Skipped uint64
+	Elapsed time.Duration
+	// Expired reports whether the deadline of the line was reached.
+	Expired bool
+}
+
+type LineOption[T any] func(*Line[T])
+
+// Scheduler controls the order in which the workers of a Line go on, it is
+// implemented by paralleltest to run the workers one by one.
+type Scheduler interface {
+	// Enter is called before the worker started, and Exit after it stopped.
+	Enter(worker uint64)
+	Exit(worker uint64)
+	// Yield blocks the worker until it is scheduled, the worker yields before
+	// it takes the next params and before it runs the action.
+	Yield(worker uint64)
+}
+
+// LineScheduler lets the scheduler control the workers.
+func LineScheduler[T any](scheduler Scheduler) LineOption[T] {
+	return func(line *Line[T]) {
+		line.scheduler = scheduler
+	}
+}
+
+// LineClock replaces the clock used for the timeouts of the line.
+func LineClock[T any](clock Clock) LineOption[T] {
+	return func(line *Line[T]) {
+		line.clock = clock
+	}
+}
+
+// LineDeadline limits the time of the line to budget since it started to run.
+// Once the budget is spent, the workers start no more params, the context
+// passed to the running actions by RunContext is canceled, and Wait returns
+// ErrDeadline. The params which are done till then are counted by WaitReport.
+func LineDeadline[T any](budget time.Duration) LineOption[T] {
+	return func(line *Line[T]) {
+		line.budget = budget
+	}
+}
+
+func NewLine[T any](size uint64, opts ...LineOption[T]) Line[T] {
+	return NewLineWithCancel(size, nil, opts...)
+}
+
+func NewLineWithContext[T any](ctx context.Context, size uint64, opts ...LineOption[T]) Line[T] {
+	return NewLineWithCancel(size, ctx.Done(), opts...)
+}
+
+func NewLineWithCancel[T any](size uint64, cancel <-chan struct{}, opts ...LineOption[T]) Line[T] {
+	line := Line[T]{
+		lineState: &lineState{done: make(chan struct{})},
+		size:      size,
+		cancel:    cancel,
+		scheduler: nopScheduler{},
+		clock:     realClock{},
+	}
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		opt(&line)
+	}
+	return line
+}
+
+// Run starts the workers, each of them takes params from supplier until it is
+// closed. The supplier must be closed by the caller, otherwise Wait never returns.
+func (a *Line[T]) Run(ctx context.Context, supplier <-chan T, action func(T) error) {
+	a.RunContext(ctx, supplier, func(_ context.Context, params T) error {
+		return action(params)
+	})
+}
+
+// RunContext is the same as Run, but the action receives the context of the
+// line, which is canceled once the deadline of the line is reached.
+func (a *Line[T]) RunContext(ctx context.Context, supplier <-chan T, action func(context.Context, T) error) {
+	ctx = a.start(ctx)
+	run := a.wrap(func(params T) error {
+		return action(ctx, params)
+	})
+
+	a.wg.Add(int(a.size))
+	for i := uint64(0); i < a.size; i++ {
+		a.scheduler.Enter(i)
+		go a.work(ctx, i, supplier, run)
+	}
+	go a.finish(nil)
 }
 
-func NewLine(size uint64) Line {
-	return Line{
-		done: make(chan struct{}, size),
-		size: size,
+func (a *Line[T]) work(ctx context.Context, worker uint64, supplier <-chan T, action func(T) error) {
+	defer a.wg.Done()
+	defer a.scheduler.Exit(worker)
+
+	for {
+		a.scheduler.Yield(worker)
+		params, ok := <-supplier
+		if !ok {
+			return
+		}
+
+		if a.stopped(ctx) {
+			a.skipped.Add(1)
+			a.skipped.Add(dropChan(supplier))
+			return
+		}
+
+		a.scheduler.Yield(worker)
+		if err := a.execute(ctx, worker, params, action); err != nil {
+			a.setErr(err)
+		}
 	}
 }
 
-func NewLineWithContext(ctx context.Context, size uint64) Line {
-	return Line{
-		done:   make(chan struct{}, size),
-		size:   size,
-		cancel: ctx.Done(),
+// execute runs the action of a params, the panic of the action is recovered
+// as its error. The outcome is counted and logged.
+func (a *Line[T]) execute(ctx context.Context, worker uint64, params T, action func(T) error) (err error) {
+	a.started.Add(1)
+	var begin time.Time
+	if a.log != nil {
+		begin = a.clock.Now()
+		a.log.start(ctx, worker, params)
 	}
+
+	defer func() {
+		var stack []byte
+		if msg := recover(); msg != nil {
+			err, stack = recovered(msg), debug.Stack()
+		}
+
+		if err == nil {
+			a.succeeded.Add(1)
+		} else {
+			a.failed.Add(1)
+		}
+		if a.log != nil {
+			a.log.finish(ctx, worker, params, a.clock.Now().Sub(begin), err, stack)
+		}
+	}()
+
+	return action(params)
 }
 
-func NewLineWithCancel(size uint64, cancel <-chan struct{}) Line {
-	return Line{
-		done:   make(chan struct{}, size),
-		size:   size,
-		cancel: cancel,
+// share returns a Line of another type of params which shares the options and
+// the state of a, the middleware of a should be applied through wrap. params
+// returns the params of a which the params of the new Line stand for.
+func share[U, T any](a *Line[T], params func(U) T) Line[U] {
+	return Line[U]{
+		size:      a.size,
+		cancel:    a.cancel,
+		scheduler: a.scheduler,
+		clock:     a.clock,
+		hooks:     a.hooks,
+		budget:    a.budget,
+		log:       adaptLog(a.log, params),
+		lineState: a.lineState,
 	}
 }
 
-func Run[T any](line *Line, supplier <-chan T, action func(T) error) {
-	for i := uint64(0); i < line.size; i++ {
-		go func() {
-			defer func() {
-				if msg := recover(); msg != nil {
-					line.anyErr = fmt.Errorf("%v", msg)
-				}
-				line.done <- struct{}{}
-			}()
+// start calls the hooks, and returns the context which is canceled at the
+// deadline of the line.
+func (a *Line[T]) start(ctx context.Context) context.Context {
+	a.begin = a.clock.Now()
+	for _, hook := range a.hooks {
+		hook()
+	}
 
-			for params := range supplier {
-				if isCanceled(line.cancel) {
-					line.anyErr = errors.New("action canceled")
-				}
+	if a.budget <= 0 {
+		return ctx
+	}
+	// the budget counts from the start, not from when the goroutine runs
+	ctx, cancel := context.WithCancelCause(ctx)
+	expired := a.clock.After(a.budget)
+	go func() {
+		select {
+		case <-expired:
+			a.expired.Store(true)
+			a.setErr(ErrDeadline)
+			cancel(ErrDeadline)
+		case <-a.done:
+			cancel(nil)
+		}
+	}()
+	return ctx
+}
 
-				if line.anyErr != nil {
-					dropChan(supplier)
-					return
-				}
+// finish closes done once all the workers stopped, after calls of last.
+func (a *Line[T]) finish(last func()) {
+	a.wg.Wait()
+	if last != nil {
+		last()
+	}
+	a.end = a.clock.Now()
+	close(a.done)
+}
 
-				err := action(params)
+// stopped reports whether the line should start no more params, and keeps
+// the reason as the error of the line.
+func (a *Line[T]) stopped(ctx context.Context) bool {
+	if isCanceled(a.cancel) {
+		a.setErr(errors.New("action canceled"))
+	}
+	if ctx.Err() != nil {
+		a.setErr(context.Cause(ctx))
+	}
+	return a.err() != nil
+}
 
-				if err != nil {
-					line.anyErr = err
-				}
-			}
-		}()
+// wrap applies the middleware to the action.
+func (a *Line[T]) wrap(action func(T) error) func(T) error {
+	for i := len(a.middleware) - 1; i >= 0; i-- {
+		action = a.middleware[i](action)
 	}
+	return action
 }
 
 // Wait until finished
-func (a *Line) Wait() error {
+func (a *Line[T]) Wait() error {
 	<-a.done
-	return a.anyErr
+	return a.err()
+}
+
+// WaitReport waits until finished, and returns the report of the params
+// as well, which holds the partial results when the deadline is reached.
+func (a *Line[T]) WaitReport() (Report, error) {
+	<-a.done
+	return Report{
+		Started: a.started.Load(),
+		Done:    a.succeeded.Load(),
+		Failed:  a.failed.Load(),
+		Skipped: a.skipped.Load(),
+		Elapsed: a.end.Sub(a.begin),
+		Expired: a.expired.Load(),
+	}, a.err()
 }
 
 // WaitTime waits for the process to finish within the given duration.
 // If the process does not finish within the duration, it returns a timeout error.
-func (a *Line) WaitTime(timeout time.Duration) error {
+func (a *Line[T]) WaitTime(timeout time.Duration) error {
 	select {
 	case <-a.done:
-		return a.anyErr
-	case <-time.After(timeout):
+		return a.err()
+	case <-a.clock.After(timeout):
 		return errors.New("wait timeout")
 	}
 }
 
 // Error return anyErr's value
-func (a *Line) Error() string {
-	return a.anyErr.Error()
+func (a *Line[T]) Error() string {
+	return a.err().Error()
+}
+
+// setErr keeps the first error, the later ones are caused by it in most cases.
+func (a *lineState) setErr(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.anyErr == nil {
+		a.anyErr = err
+	}
 }
 
+func (a *lineState) err() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.anyErr
+}
+
+// recovered covert the value of recover() to an error
+func recovered(msg any) error {
+	if err, ok := msg.(error); ok {
+		return err
+	}
+	return fmt.Errorf("%v", msg)
+}
+
+type nopScheduler struct{}
+
+func (nopScheduler) Enter(uint64) {}
+func (nopScheduler) Exit(uint64)  {}
+func (nopScheduler) Yield(uint64) {}
+
 func isCanceled(cancel <-chan struct{}) bool {
 	select {
 	case <-cancel:
@@ -98,8 +339,10 @@ func isCanceled(cancel <-chan struct{}) bool {
 	}
 }
 
-// dropChan clean the chan avoid block
-func dropChan[T any](supplier <-chan T) {
+// dropChan clean the chan avoid block, and returns the number of the dropped items.
+func dropChan[T any](supplier <-chan T) (n uint64) {
 	for range supplier {
+		n++
 	}
+	return
 }
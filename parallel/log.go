@@ -0,0 +1,114 @@
+package parallel
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+type LogOption[T any] func(*lineLog[T])
+
+// LogKey sets the key of the params, which is logged as the "key" attribute.
+func LogKey[T any](key func(T) any) LogOption[T] {
+	return func(l *lineLog[T]) {
+		l.key = key
+	}
+}
+
+// LogAttempt sets the attempt of the params, which is logged as the "attempt"
+// attribute, e.g. the params which are put back into the supplier for retry.
+func LogAttempt[T any](attempt func(T) int) LogOption[T] {
+	return func(l *lineLog[T]) {
+		l.attempt = attempt
+	}
+}
+
+// LogStartLevel sets the level of the start of the params, default is Debug.
+func LogStartLevel[T any](level slog.Level) LogOption[T] {
+	return func(l *lineLog[T]) {
+		l.startLevel = level
+	}
+}
+
+// LogFailLevel sets the level of the failure of the params, default is Error.
+func LogFailLevel[T any](level slog.Level) LogOption[T] {
+	return func(l *lineLog[T]) {
+		l.failLevel = level
+	}
+}
+
+// LineLogger logs the params of the line to logger. The workers log the start
+// and the failure of every params with the "worker", "key", "attempt" and
+// "duration" attributes, and the stack of a panic as the "stack" attribute.
+func LineLogger[T any](logger *slog.Logger, opts ...LogOption[T]) LineOption[T] {
+	l := &lineLog[T]{logger: logger, startLevel: slog.LevelDebug, failLevel: slog.LevelError}
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		opt(l)
+	}
+
+	return func(line *Line[T]) {
+		if logger == nil {
+			line.log = nil
+			return
+		}
+		line.log = l
+	}
+}
+
+// lineLog logs the params of a Line, a nil lineLog logs nothing.
+type lineLog[T any] struct {
+	logger                *slog.Logger
+	key                   func(T) any
+	attempt               func(T) int
+	startLevel, failLevel slog.Level
+}
+
+// adaptLog returns the lineLog of the params of another type, which logs the
+// attributes of the params they stand for.
+func adaptLog[U, T any](l *lineLog[T], params func(U) T) *lineLog[U] {
+	if l == nil {
+		return nil
+	}
+
+	adapted := &lineLog[U]{logger: l.logger, startLevel: l.startLevel, failLevel: l.failLevel}
+	if key := l.key; key != nil {
+		adapted.key = func(u U) any { return key(params(u)) }
+	}
+	if attempt := l.attempt; attempt != nil {
+		adapted.attempt = func(u U) int { return attempt(params(u)) }
+	}
+	return adapted
+}
+
+func (l *lineLog[T]) start(ctx context.Context, worker uint64, params T) {
+	if l == nil || !l.logger.Enabled(ctx, l.startLevel) {
+		return
+	}
+	l.logger.LogAttrs(ctx, l.startLevel, "params started", l.attrs(worker, params)...)
+}
+
+func (l *lineLog[T]) finish(ctx context.Context, worker uint64, params T, elapsed time.Duration, err error, stack []byte) {
+	if l == nil || err == nil || !l.logger.Enabled(ctx, l.failLevel) {
+		return
+	}
+
+	attrs := append(l.attrs(worker, params), slog.Duration("duration", elapsed), slog.Any("error", err))
+	if stack != nil {
+		attrs = append(attrs, slog.String("stack", string(stack)))
+	}
+	l.logger.LogAttrs(ctx, l.failLevel, "params failed", attrs...)
+}
+
+func (l *lineLog[T]) attrs(worker uint64, params T) []slog.Attr {
+	attrs := []slog.Attr{slog.Uint64("worker", worker)}
+	if l.key != nil {
+		attrs = append(attrs, slog.Any("key", l.key(params)))
+	}
+	if l.attempt != nil {
+		attrs = append(attrs, slog.Int("attempt", l.attempt(params)))
+	}
+	return attrs
+}
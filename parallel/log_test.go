@@ -0,0 +1,121 @@
+package parallel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// logBuffer collects the records of a JSON handler.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) records(t *testing.T) []map[string]any {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var records []map[string]any
+	dec := json.NewDecoder(&b.buf)
+	for dec.More() {
+		record := map[string]any{}
+		if !assert.NoError(t, dec.Decode(&record)) {
+			break
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestLineLogger(t *testing.T) {
+	buf := &logBuffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	line, supplier := NewLine(1, LineLogger(logger,
+		LogKey(func(i int) any { return i }),
+		LogAttempt(func(i int) int { return 1 }),
+	)), make(chan int)
+	line.Run(context.Background(), supplier, func(i int) error {
+		if i == 1 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+
+	supplier <- 0
+	supplier <- 1
+	close(supplier)
+	assert.Error(t, line.Wait())
+
+	records := buf.records(t)
+	if !assert.Len(t, records, 3) {
+		return
+	}
+	for i, msg := range []string{"params started", "params started", "params failed"} {
+		assert.Equal(t, msg, records[i]["msg"])
+		assert.Equal(t, float64(0), records[i]["worker"])
+		assert.Equal(t, float64(1), records[i]["attempt"])
+	}
+	assert.Equal(t, "DEBUG", records[0]["level"])
+	assert.Equal(t, float64(0), records[0]["key"])
+
+	failed := records[2]
+	assert.Equal(t, "ERROR", failed["level"])
+	assert.Equal(t, float64(1), failed["key"])
+	assert.Equal(t, "failed", failed["error"])
+	assert.Contains(t, failed, "duration")
+	assert.NotContains(t, failed, "stack")
+}
+
+func TestLineLoggerPanic(t *testing.T) {
+	buf := &logBuffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, nil))
+
+	line, supplier := NewLine(1, LineLogger(logger, LogFailLevel[int](slog.LevelWarn))), make(chan int)
+	line.Run(context.Background(), supplier, func(i int) error {
+		panic("boom")
+	})
+
+	supplier <- 0
+	close(supplier)
+	assert.Error(t, line.Wait())
+
+	// the start is not logged at the default Debug level
+	records := buf.records(t)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "WARN", records[0]["level"])
+		assert.NotContains(t, records[0], "key")
+		assert.Contains(t, records[0]["stack"], "log_test.go")
+	}
+}
+
+func TestLineLoggerShared(t *testing.T) {
+	buf := &logBuffer{}
+	logger := slog.New(slog.NewJSONHandler(buf, nil))
+
+	line := NewLine(1, LineLogger(logger, LogKey(func(s string) any { return s })))
+	futures := Async(context.Background(), &line, []string{"a"}, func(s string) (int, error) {
+		return 0, errors.New("failed")
+	})
+	_, err := futures[0].Get(context.Background())
+	assert.Error(t, err)
+	assert.Error(t, line.Wait())
+
+	records := buf.records(t)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "a", records[0]["key"])
+	}
+}
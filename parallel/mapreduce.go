@@ -0,0 +1,77 @@
+package parallel
+
+import (
+	"context"
+	"sync"
+)
+
+// MapReduce runs mapper over the input on a Line of n workers, and reduces
+// the values which mapper emits by their key. The input must be closed by
+// the caller.
+//
+// The values are combined in the local map of each worker first, and the
+// local maps are merged in parallel at last. The order in which the values
+// meet depends on the scheduling, so reducer must be both associative and
+// commutative, and it is not called concurrently for the same map. It
+// returns the first error of the line, the same as Line.Wait.
+func MapReduce[T any, K comparable, V any](ctx context.Context, input <-chan T, mapper func(T, func(K, V)) error, reducer func(V, V) V, n uint64) (map[K]V, error) {
+	n = max(n, 1)
+
+	// each running action takes a local map, there are at most n of them
+	locals := make(chan map[K]V, n)
+	for i := uint64(0); i < n; i++ {
+		locals <- make(map[K]V)
+	}
+
+	line := NewLine[T](n)
+	line.Run(ctx, input, func(params T) error {
+		local := <-locals
+		defer func() { locals <- local }()
+
+		return mapper(params, func(key K, val V) {
+			if prev, ok := local[key]; ok {
+				val = reducer(prev, val)
+			}
+			local[key] = val
+		})
+	})
+	if err := line.Wait(); err != nil {
+		return nil, err
+	}
+
+	close(locals)
+	maps := make([]map[K]V, 0, n)
+	for local := range locals {
+		maps = append(maps, local)
+	}
+	return merge(maps, reducer), nil
+}
+
+// merge merges the maps in pairs concurrently, until only one is left.
+func merge[K comparable, V any](maps []map[K]V, reducer func(V, V) V) map[K]V {
+	for len(maps) > 1 {
+		var wg sync.WaitGroup
+		next := make([]map[K]V, (len(maps)+1)/2)
+		for i := range next {
+			if 2*i+1 == len(maps) {
+				next[i] = maps[2*i]
+				continue
+			}
+
+			wg.Add(1)
+			go func(dst, src map[K]V) {
+				defer wg.Done()
+				for key, val := range src {
+					if prev, ok := dst[key]; ok {
+						val = reducer(prev, val)
+					}
+					dst[key] = val
+				}
+				next[i] = dst
+			}(maps[2*i], maps[2*i+1])
+		}
+		wg.Wait()
+		maps = next
+	}
+	return maps[0]
+}
@@ -0,0 +1,51 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func words(lines []string) <-chan string {
+	input := make(chan string)
+	go func() {
+		for _, line := range lines {
+			input <- line
+		}
+		close(input)
+	}()
+	return input
+}
+
+func TestMapReduce(t *testing.T) {
+	lines := []string{"a b c", "a b", "a", "c c"}
+
+	count, err := MapReduce(context.Background(), words(lines), func(line string, emit func(string, int)) error {
+		for _, word := range strings.Fields(line) {
+			emit(word, 1)
+		}
+		return nil
+	}, func(a, b int) int { return a + b }, 3)
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"a": 3, "b": 2, "c": 3}, count)
+}
+
+func TestMapReduceError(t *testing.T) {
+	_, err := MapReduce(context.Background(), words([]string{"a", "b"}), func(line string, emit func(string, int)) error {
+		if line == "b" {
+			return errors.New("errmsg")
+		}
+		emit(line, 1)
+		return nil
+	}, func(a, b int) int { return a + b }, 2)
+	assert.EqualError(t, err, "errmsg")
+}
+
+func TestMerge(t *testing.T) {
+	maps := []map[int]int{{1: 1}, {1: 1, 2: 1}, {2: 1}, {3: 1}, {1: 1}}
+	assert.Equal(t, map[int]int{1: 3, 2: 2, 3: 1}, merge(maps, func(a, b int) int { return a + b }))
+}
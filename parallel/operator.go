@@ -0,0 +1,174 @@
+package parallel
+
+import (
+	"context"
+	"time"
+)
+
+// The operators read the input chan in a goroutine and return the output
+// chan, which is closed once the input is closed or ctx is done. The pending
+// items are sent before the output is closed if the input is closed.
+
+type OperatorOption func(*operatorConfig)
+
+type operatorConfig struct {
+	clock Clock
+}
+
+// OperatorClock replaces the clock of the operator.
+func OperatorClock(clock Clock) OperatorOption {
+	return func(c *operatorConfig) {
+		c.clock = clock
+	}
+}
+
+func newOperatorConfig(opts []OperatorOption) operatorConfig {
+	config := operatorConfig{clock: realClock{}}
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		opt(&config)
+	}
+	return config
+}
+
+// send sends val to out, it returns false if ctx is done first.
+func send[T any](ctx context.Context, out chan<- T, val T) bool {
+	select {
+	case out <- val:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Debounce sends the latest item once the input is quiet for the duration.
+func Debounce[T any](ctx context.Context, in <-chan T, quiet time.Duration, opts ...OperatorOption) <-chan T {
+	config, out := newOperatorConfig(opts), make(chan T)
+
+	go func() {
+		defer close(out)
+
+		var (
+			latest  T
+			pending bool
+			timer   <-chan time.Time
+		)
+		for {
+			select {
+			case item, ok := <-in:
+				if !ok {
+					if pending {
+						send(ctx, out, latest)
+					}
+					return
+				}
+				latest, pending = item, true
+				timer = config.clock.After(quiet)
+			case <-timer:
+				timer, pending = nil, false
+				if !send(ctx, out, latest) {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Throttle sends at most one item per interval, the items which arrive within
+// the interval after the last sent one are dropped.
+func Throttle[T any](ctx context.Context, in <-chan T, interval time.Duration, opts ...OperatorOption) <-chan T {
+	config, out := newOperatorConfig(opts), make(chan T)
+
+	go func() {
+		defer close(out)
+
+		var last time.Time
+		sent := false
+		for {
+			select {
+			case item, ok := <-in:
+				if !ok {
+					return
+				}
+				if now := config.clock.Now(); !sent || now.Sub(last) >= interval {
+					if !send(ctx, out, item) {
+						return
+					}
+					last, sent = now, true
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Coalesce merges the items which share a key within the window, the window
+// of a key starts at its first item, and the merged item is sent at its end.
+func Coalesce[T any, K comparable](ctx context.Context, in <-chan T, window time.Duration, key func(T) K, merge func(merged, item T) T, opts ...OperatorOption) <-chan T {
+	config, out := newOperatorConfig(opts), make(chan T)
+
+	type entry struct {
+		key      K
+		deadline time.Time
+	}
+
+	go func() {
+		defer close(out)
+
+		// the windows have the same length, so the keys end in the order
+		// of their first item
+		merged, order := make(map[K]T), []entry(nil)
+		var (
+			timer    <-chan time.Time
+			timerFor time.Time
+		)
+		flush := func() bool {
+			head := order[0]
+			order = order[1:]
+			item := merged[head.key]
+			delete(merged, head.key)
+			return send(ctx, out, item)
+		}
+
+		for {
+			// wait for the end of the earliest window
+			if len(order) > 0 && (timer == nil || !timerFor.Equal(order[0].deadline)) {
+				timer, timerFor = config.clock.After(order[0].deadline.Sub(config.clock.Now())), order[0].deadline
+			}
+
+			select {
+			case item, ok := <-in:
+				if !ok {
+					for len(order) > 0 {
+						if !flush() {
+							return
+						}
+					}
+					return
+				}
+				k := key(item)
+				if val, ok := merged[k]; ok {
+					merged[k] = merge(val, item)
+					continue
+				}
+				merged[k] = item
+				order = append(order, entry{key: k, deadline: config.clock.Now().Add(window)})
+			case <-timer:
+				timer = nil
+				if !flush() {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,116 @@
+package parallel_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/EAFA0/Tool/parallel"
+	"github.com/EAFA0/Tool/parallel/paralleltest"
+	"github.com/stretchr/testify/assert"
+)
+
+// collect receives all the items of out.
+func collect[T any](out <-chan T) (items []T) {
+	for item := range out {
+		items = append(items, item)
+	}
+	return
+}
+
+func TestDebounce(t *testing.T) {
+	clock, in := paralleltest.NewClock(time.Unix(0, 0)), make(chan int)
+	out := parallel.Debounce(context.Background(), in, time.Second, parallel.OperatorClock(clock))
+
+	in <- 1
+	in <- 2
+	clock.BlockUntil(2)
+	clock.Advance(time.Second)
+	assert.Equal(t, 2, <-out)
+
+	// the quiet time restarts with each item
+	in <- 3
+	clock.BlockUntil(1)
+	clock.Advance(time.Second / 2)
+	in <- 4
+	clock.BlockUntil(2)
+	clock.Advance(time.Second / 2)
+	assert.Len(t, out, 0)
+
+	// the pending item is sent when the input is closed
+	close(in)
+	assert.Equal(t, []int{4}, collect(out))
+}
+
+// tickClock moves forward by step on each call of Now, Throttle calls Now
+// once for each item.
+type tickClock struct {
+	*paralleltest.Clock
+	step time.Duration
+}
+
+func (c tickClock) Now() time.Time {
+	c.Advance(c.step)
+	return c.Clock.Now()
+}
+
+func TestThrottle(t *testing.T) {
+	clock, in := tickClock{paralleltest.NewClock(time.Unix(0, 0)), 400 * time.Millisecond}, make(chan int)
+	out := parallel.Throttle(context.Background(), in, time.Second, parallel.OperatorClock(clock))
+
+	go func() {
+		// the items arrive at 0.4s, 0.8s, 1.2s, 1.6s, 2.0s and 2.4s
+		for i := 1; i <= 6; i++ {
+			in <- i
+		}
+		close(in)
+	}()
+	assert.Equal(t, []int{1, 4}, collect(out))
+}
+
+type event struct {
+	key   string
+	count int
+}
+
+func TestCoalesce(t *testing.T) {
+	clock, in := paralleltest.NewClock(time.Unix(0, 0)), make(chan event)
+	out := parallel.Coalesce(context.Background(), in, time.Second, func(e event) string {
+		return e.key
+	}, func(merged, item event) event {
+		merged.count += item.count
+		return merged
+	}, parallel.OperatorClock(clock))
+
+	in <- event{"a", 1}
+	clock.Advance(time.Second / 2)
+	in <- event{"b", 1}
+	in <- event{"a", 1}
+
+	// the window of a ends first
+	clock.BlockUntil(1)
+	clock.Advance(time.Second / 2)
+	assert.Equal(t, event{"a", 2}, <-out)
+
+	in <- event{"b", 1}
+	in <- event{"a", 1}
+	close(in)
+	assert.Equal(t, []event{{"b", 2}, {"a", 1}}, collect(out))
+}
+
+func TestOperatorCancel(t *testing.T) {
+	paralleltest.CheckLeaks(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan int)
+	outs := []<-chan int{
+		parallel.Debounce(ctx, in, time.Second),
+		parallel.Throttle(ctx, in, time.Second),
+		parallel.Coalesce(ctx, in, time.Second, func(i int) int { return i }, func(merged, _ int) int { return merged }),
+	}
+
+	cancel()
+	for _, out := range outs {
+		assert.Empty(t, collect(out))
+	}
+}
@@ -0,0 +1,48 @@
+package paralleltest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/EAFA0/Tool/parallel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClockAdvance(t *testing.T) {
+	start := time.Unix(0, 0)
+	clock := NewClock(start)
+
+	late, early := clock.After(2*time.Second), clock.After(time.Second)
+	assert.Equal(t, 2, clock.Waiters())
+
+	clock.Advance(time.Second)
+	assert.Equal(t, start.Add(time.Second), <-early)
+	assert.Len(t, late, 0)
+
+	clock.Advance(time.Second)
+	assert.Equal(t, start.Add(2*time.Second), <-late)
+	assert.Equal(t, start.Add(2*time.Second), clock.Now())
+	assert.Equal(t, 0, clock.Waiters())
+}
+
+func TestClockWaitTime(t *testing.T) {
+	clock := NewClock(time.Unix(0, 0))
+	line, supplier := parallel.NewLine(1, parallel.LineClock[int](clock)), make(chan int)
+	line.Run(context.Background(), supplier, func(int) error {
+		return nil
+	})
+
+	result := make(chan error)
+	go func() {
+		result <- line.WaitTime(time.Minute)
+	}()
+
+	// the timeout is reached without waiting for a real minute
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	assert.EqualError(t, <-result, "wait timeout")
+
+	close(supplier)
+	assert.NoError(t, line.Wait())
+}
@@ -0,0 +1,98 @@
+package paralleltest
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// Executor is a parallel.Scheduler which runs the workers of a Line one by
+// one, in an order which is decided by the seed.
+//
+// Each Step waits until every worker is blocked in Yield or stopped, and then
+// lets one of them go on to its next Yield. The supplier of the line should be
+// fed by another goroutine, or be buffered, since the workers do not take
+// params between the steps.
+type Executor struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	rnd    *rand.Rand
+	live   map[uint64]struct{}
+	parked map[uint64]chan struct{}
+	trace  []uint64
+}
+
+func NewExecutor(seed int64) *Executor {
+	e := &Executor{
+		rnd:    rand.New(rand.NewSource(seed)),
+		live:   make(map[uint64]struct{}),
+		parked: make(map[uint64]chan struct{}),
+	}
+	e.cond = sync.NewCond(&e.mu)
+	return e
+}
+
+func (e *Executor) Enter(worker uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.live[worker] = struct{}{}
+}
+
+func (e *Executor) Exit(worker uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.live, worker)
+	e.cond.Broadcast()
+}
+
+func (e *Executor) Yield(worker uint64) {
+	e.mu.Lock()
+	next := make(chan struct{})
+	e.parked[worker] = next
+	e.cond.Broadcast()
+	e.mu.Unlock()
+
+	<-next
+}
+
+// Step lets one of the workers go on, it returns false if all the workers
+// are stopped.
+func (e *Executor) Step() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for len(e.parked) < len(e.live) {
+		e.cond.Wait()
+	}
+	if len(e.live) == 0 {
+		return false
+	}
+
+	// sort the workers, the order of a map is random
+	workers := make([]uint64, 0, len(e.parked))
+	for worker := range e.parked {
+		workers = append(workers, worker)
+	}
+	sort.Slice(workers, func(i, j int) bool {
+		return workers[i] < workers[j]
+	})
+
+	worker := workers[e.rnd.Intn(len(workers))]
+	close(e.parked[worker])
+	delete(e.parked, worker)
+	e.trace = append(e.trace, worker)
+	return true
+}
+
+// Run steps until all the workers are stopped.
+func (e *Executor) Run() {
+	for e.Step() {
+	}
+}
+
+// Trace returns the workers in the order they were scheduled.
+func (e *Executor) Trace() []uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]uint64(nil), e.trace...)
+}
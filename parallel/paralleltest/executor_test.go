@@ -0,0 +1,84 @@
+package paralleltest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/EAFA0/Tool/parallel"
+	"github.com/stretchr/testify/assert"
+)
+
+// runSeed runs a line on the executor of seed, and returns the order of params.
+func runSeed(seed int64) (order []int, trace []uint64) {
+	executor := NewExecutor(seed)
+	line, supplier := parallel.NewLine(3, parallel.LineScheduler[int](executor)), make(chan int, 10)
+	for i := 0; i < 10; i++ {
+		supplier <- i
+	}
+	close(supplier)
+
+	// the workers run one by one, so the order needs no lock
+	line.Run(context.Background(), supplier, func(params int) error {
+		order = append(order, params)
+		return nil
+	})
+	executor.Run()
+	line.Wait()
+
+	return order, executor.Trace()
+}
+
+func TestExecutorSeed(t *testing.T) {
+	order, trace := runSeed(1)
+	assert.Len(t, order, 10)
+
+	// the same seed runs the same order
+	for i := 0; i < 10; i++ {
+		again, againTrace := runSeed(1)
+		assert.Equal(t, order, again)
+		assert.Equal(t, trace, againTrace)
+	}
+}
+
+func TestExecutorError(t *testing.T) {
+	CheckLeaks(t)
+
+	executor := NewExecutor(1)
+	line, supplier := parallel.NewLine(2, parallel.LineScheduler[int](executor)), make(chan int)
+	line.Run(context.Background(), supplier, func(params int) error {
+		if params == 3 {
+			return errors.New("errmsg")
+		}
+		return nil
+	})
+
+	go func() {
+		for i := 0; i < 10; i++ {
+			supplier <- i
+		}
+		close(supplier)
+	}()
+	executor.Run()
+
+	assert.EqualError(t, line.Wait(), "errmsg")
+}
+
+func TestExecutorStealLine(t *testing.T) {
+	run := func() []uint64 {
+		executor := NewExecutor(2)
+		line := parallel.NewStealLine(3, parallel.LineScheduler[int](executor))
+		line.Run(context.Background(), []int{4}, func(item int, spawn func(int)) error {
+			for i := 0; i < item; i++ {
+				spawn(i)
+			}
+			return nil
+		})
+		executor.Run()
+		assert.NoError(t, line.Wait())
+		return executor.Trace()
+	}
+
+	trace := run()
+	assert.Equal(t, trace, run())
+}
@@ -0,0 +1,71 @@
+package paralleltest
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// leakTimeout is how long the goroutines are given to stop after the test.
+const leakTimeout = time.Second
+
+// CheckLeaks fails the test if goroutines which are started during the test
+// are still running once it finished.
+func CheckLeaks(t testing.TB) {
+	t.Helper()
+
+	before := goroutines()
+	t.Cleanup(func() {
+		var leaked []string
+		for deadline := time.Now().Add(leakTimeout); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			if leaked = leaks(before); len(leaked) == 0 {
+				return
+			}
+		}
+		t.Errorf("found %d leaked goroutines:\n\n%s", len(leaked), strings.Join(leaked, "\n\n"))
+	})
+}
+
+// leaks returns the stacks of the goroutines which are not in before.
+func leaks(before map[string]string) (leaked []string) {
+	for id, stack := range goroutines() {
+		if _, ok := before[id]; !ok && !ignored(stack) {
+			leaked = append(leaked, stack)
+		}
+	}
+	return
+}
+
+// ignored reports whether the goroutine belongs to the runtime or testing.
+func ignored(stack string) bool {
+	lines := strings.SplitN(stack, "\n", 3)
+	if len(lines) < 2 {
+		return true
+	}
+	return strings.HasPrefix(lines[1], "testing.") || strings.HasPrefix(lines[1], "runtime.")
+}
+
+// goroutines returns the stacks of all the goroutines by their id, except
+// the current one.
+func goroutines() map[string]string {
+	buf := make([]byte, 1<<16)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+
+	stacks := bytes.Split(buf, []byte("\n\n"))
+	res := make(map[string]string, len(stacks))
+	// the first one is the current goroutine
+	for _, stack := range stacks[1:] {
+		head, _, _ := strings.Cut(string(stack), " [")
+		res[head] = string(stack)
+	}
+	return res
+}
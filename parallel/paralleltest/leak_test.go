@@ -0,0 +1,49 @@
+package paralleltest
+
+import (
+	"fmt"
+	"testing"
+)
+
+// recorder records the errors instead of failing the test.
+type recorder struct {
+	testing.TB
+	cleanup []func()
+	errs    []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Cleanup(fn func()) {
+	r.cleanup = append(r.cleanup, fn)
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) finish() {
+	for _, fn := range r.cleanup {
+		fn()
+	}
+}
+
+func TestCheckLeaks(t *testing.T) {
+	r, stop := &recorder{TB: t}, make(chan struct{})
+	CheckLeaks(r)
+	go func() { <-stop }()
+	r.finish()
+
+	if len(r.errs) != 1 {
+		t.Fatalf("the leak is not found: %v", r.errs)
+	}
+
+	r = &recorder{TB: t}
+	CheckLeaks(r)
+	close(stop)
+	r.finish()
+
+	if len(r.errs) != 0 {
+		t.Fatalf("unexpected leak: %v", r.errs)
+	}
+}
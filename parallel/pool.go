@@ -0,0 +1,265 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrPoolClosed is returned by the submissions after Shutdown.
+	ErrPoolClosed = errors.New("pool is closed")
+	// ErrPoolFull is returned by the submissions when the queue is full and
+	// the pool rejects instead of blocking.
+	ErrPoolFull = errors.New("pool queue is full")
+)
+
+// Pool is a long-lived set of workers which accepts tasks until Shutdown.
+//
+// The workers are started on demand up to size, and stop after they are idle
+// for the idle timeout. The tasks which wait for a worker are kept in a queue
+// with bounded depth, the submission blocks or is rejected when it is full.
+type Pool struct {
+	size   uint64
+	idle   time.Duration
+	reject bool
+	clock  Clock
+	// slots bounds the depth of the queue
+	slots chan struct{}
+	// wake hands the queued tasks to the idle workers
+	wake chan struct{}
+	quit chan struct{}
+	wg   sync.WaitGroup
+
+	mu      sync.Mutex
+	queue   []func()
+	workers uint64
+	waiting uint64
+	closed  bool
+}
+
+type PoolOption func(*poolConfig)
+
+type poolConfig struct {
+	queue  int
+	idle   time.Duration
+	reject bool
+	clock  Clock
+}
+
+// PoolQueue sets the depth of the queue, default is the size of the pool.
+// The depth is at least 1.
+func PoolQueue(depth int) PoolOption {
+	return func(c *poolConfig) {
+		c.queue = depth
+	}
+}
+
+// PoolReject makes the submissions fail with ErrPoolFull when the queue is
+// full, instead of blocking.
+func PoolReject() PoolOption {
+	return func(c *poolConfig) {
+		c.reject = true
+	}
+}
+
+// PoolIdleTimeout sets how long an idle worker waits for a task before it
+// stops, default is 1 minute.
+func PoolIdleTimeout(idle time.Duration) PoolOption {
+	return func(c *poolConfig) {
+		c.idle = idle
+	}
+}
+
+// PoolClock replaces the clock used for the idle timeout of the workers.
+func PoolClock(clock Clock) PoolOption {
+	return func(c *poolConfig) {
+		c.clock = clock
+	}
+}
+
+func NewPool(size uint64, opts ...PoolOption) *Pool {
+	config := poolConfig{queue: int(size), idle: time.Minute, clock: realClock{}}
+	for _, opt := range opts {
+		if opt == nil {
+			continue
+		}
+		opt(&config)
+	}
+
+	return &Pool{
+		size:   max(size, 1),
+		idle:   config.idle,
+		reject: config.reject,
+		clock:  config.clock,
+		slots:  make(chan struct{}, max(config.queue, 1)),
+		wake:   make(chan struct{}, max(size, 1)),
+		quit:   make(chan struct{}),
+	}
+}
+
+// Submit runs job in the pool, and returns the future of its result.
+//
+// ctx bounds the wait for a place in the queue, and is passed to job as well.
+// If ctx is done before job started, job is skipped and the future holds
+// ctx.Err(). A panic of job is recovered as the error of the future, the same
+// as Line does.
+func Submit[T any](ctx context.Context, p *Pool, job func(context.Context) (T, error)) (*Future[T], error) {
+	future := newFuture[T]()
+	err := p.enqueue(ctx, func() {
+		var (
+			val T
+			err error
+		)
+		defer func() {
+			if msg := recover(); msg != nil {
+				err = recovered(msg)
+			}
+			future.resolve(val, err)
+		}()
+
+		if err = ctx.Err(); err == nil {
+			val, err = job(ctx)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return future, nil
+}
+
+// enqueue puts the task into the queue, the task must not panic.
+func (p *Pool) enqueue(ctx context.Context, task func()) error {
+	if err := p.acquire(ctx); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		<-p.slots
+		return ErrPoolClosed
+	}
+
+	p.queue = append(p.queue, task)
+	switch {
+	case p.waiting > 0:
+		p.waiting--
+		p.wake <- struct{}{}
+	case p.workers < p.size:
+		p.workers++
+		p.wg.Add(1)
+		go p.work()
+	}
+	return nil
+}
+
+// acquire takes a place in the queue.
+func (p *Pool) acquire(ctx context.Context) error {
+	if p.reject {
+		select {
+		case <-p.quit:
+			return ErrPoolClosed
+		case p.slots <- struct{}{}:
+			return nil
+		default:
+			return ErrPoolFull
+		}
+	}
+
+	select {
+	case <-p.quit:
+		return ErrPoolClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	case p.slots <- struct{}{}:
+		return nil
+	}
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+
+	for {
+		p.mu.Lock()
+		if len(p.queue) > 0 {
+			task := p.queue[0]
+			p.queue = p.queue[1:]
+			p.mu.Unlock()
+
+			<-p.slots
+			task()
+			continue
+		}
+		if p.closed {
+			p.workers--
+			p.mu.Unlock()
+			return
+		}
+		p.waiting++
+		p.mu.Unlock()
+
+		expired, stop := p.idleTimer()
+		select {
+		case <-p.wake:
+			stop()
+			continue
+		case <-p.quit:
+			stop()
+		case <-expired:
+		}
+
+		p.mu.Lock()
+		select {
+		case <-p.wake:
+			// the waiting count is decreased by the one which woke us
+			p.mu.Unlock()
+			continue
+		default:
+			p.waiting--
+		}
+		if p.closed {
+			p.mu.Unlock()
+			continue
+		}
+		p.workers--
+		p.mu.Unlock()
+		return
+	}
+}
+
+// idleTimer starts the idle timeout of a worker, the timer of the real clock
+// is stopped early so the woken workers do not leave it behind.
+func (p *Pool) idleTimer() (<-chan time.Time, func()) {
+	if _, ok := p.clock.(realClock); ok {
+		timer := time.NewTimer(p.idle)
+		return timer.C, func() { timer.Stop() }
+	}
+	return p.clock.After(p.idle), func() {}
+}
+
+// Shutdown stops accepting tasks, and waits until the queued and running
+// tasks finished. It returns ctx.Err() if ctx is done first, the tasks keep
+// running in that case.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.quit)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,152 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolSubmit(t *testing.T) {
+	pool := NewPool(2)
+
+	futures := make([]*Future[int], 10)
+	for i := range futures {
+		future, err := Submit(context.Background(), pool, func(context.Context) (int, error) {
+			return i * i, nil
+		})
+		assert.NoError(t, err)
+		futures[i] = future
+	}
+
+	for i, future := range futures {
+		val, err := future.Get(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, i*i, val)
+	}
+	assert.NoError(t, pool.Shutdown(context.Background()))
+}
+
+func TestPoolPanic(t *testing.T) {
+	pool := NewPool(1)
+	defer pool.Shutdown(context.Background())
+
+	future, err := Submit(context.Background(), pool, func(context.Context) (int, error) {
+		panic("panic msg")
+	})
+	assert.NoError(t, err)
+	_, err = future.Get(context.Background())
+	assert.EqualError(t, err, "panic msg")
+
+	// the worker survives the panic
+	future, _ = Submit(context.Background(), pool, func(context.Context) (int, error) {
+		return 0, errors.New("errmsg")
+	})
+	_, err = future.Get(context.Background())
+	assert.EqualError(t, err, "errmsg")
+}
+
+// blockJob returns a job which reports its start and blocks until release is closed.
+func blockJob(started chan<- struct{}, release <-chan struct{}) func(context.Context) (int, error) {
+	return func(context.Context) (int, error) {
+		started <- struct{}{}
+		<-release
+		return 0, nil
+	}
+}
+
+func TestPoolQueue(t *testing.T) {
+	pool, release := NewPool(1, PoolQueue(1), PoolReject()), make(chan struct{})
+	defer pool.Shutdown(context.Background())
+
+	started := make(chan struct{}, 2)
+	block := blockJob(started, release)
+	running, err := Submit(context.Background(), pool, block)
+	assert.NoError(t, err)
+	// wait until the first job leaves the queue
+	<-started
+
+	_, err = Submit(context.Background(), pool, block)
+	assert.NoError(t, err)
+	_, err = Submit(context.Background(), pool, block)
+	assert.ErrorIs(t, err, ErrPoolFull)
+
+	close(release)
+	_, err = running.Get(context.Background())
+	assert.NoError(t, err)
+}
+
+func TestPoolBlock(t *testing.T) {
+	pool, release := NewPool(1, PoolQueue(1)), make(chan struct{})
+	defer pool.Shutdown(context.Background())
+
+	started := make(chan struct{}, 2)
+	block := blockJob(started, release)
+	Submit(context.Background(), pool, block)
+	<-started
+	Submit(context.Background(), pool, block)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := Submit(ctx, pool, block)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	close(release)
+}
+
+func TestPoolShutdown(t *testing.T) {
+	pool, done := NewPool(2, PoolIdleTimeout(time.Millisecond)), int32(0)
+
+	for i := 0; i < 4; i++ {
+		Submit(context.Background(), pool, func(context.Context) (int, error) {
+			atomic.AddInt32(&done, 1)
+			return 0, nil
+		})
+	}
+	assert.NoError(t, pool.Shutdown(context.Background()))
+	assert.Equal(t, int32(4), atomic.LoadInt32(&done))
+
+	_, err := Submit(context.Background(), pool, func(context.Context) (int, error) {
+		return 0, nil
+	})
+	assert.ErrorIs(t, err, ErrPoolClosed)
+}
+
+// idleClock fires the idle timeout of the workers through fire.
+type idleClock struct {
+	realClock
+	fire chan time.Time
+}
+
+func (c idleClock) After(time.Duration) <-chan time.Time {
+	return c.fire
+}
+
+func TestPoolIdle(t *testing.T) {
+	clock := idleClock{fire: make(chan time.Time)}
+	pool := NewPool(2, PoolClock(clock))
+	defer pool.Shutdown(context.Background())
+
+	future, _ := Submit(context.Background(), pool, func(context.Context) (int, error) {
+		return 1, nil
+	})
+	future.Get(context.Background())
+
+	// the only worker is reaped once its idle timeout fired
+	clock.fire <- time.Time{}
+	pool.wg.Wait()
+
+	pool.mu.Lock()
+	assert.Equal(t, uint64(0), pool.workers)
+	pool.mu.Unlock()
+
+	// the reaped workers are started again on demand
+	future, _ = Submit(context.Background(), pool, func(context.Context) (int, error) {
+		return 2, nil
+	})
+	val, err := future.Get(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, val)
+}
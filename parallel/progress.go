@@ -0,0 +1,128 @@
+package parallel
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Progress is a snapshot of a Line which runs a known number of items.
+type Progress struct {
+	Total, Done, Failed uint64
+	Elapsed             time.Duration
+	// Throughput is the number of finished items per second.
+	Throughput float64
+	// ETA is the estimated time until all the items finished, it is zero
+	// before any item finished.
+	ETA time.Duration
+}
+
+func (p Progress) String() string {
+	return fmt.Sprintf("%d/%d done, %d failed, %.1f/s, elapsed %s, ETA %s",
+		p.Done, p.Total, p.Failed, p.Throughput,
+		p.Elapsed.Round(time.Second), p.ETA.Round(time.Second))
+}
+
+// LineProgress reports the progress of the line which runs total items. The
+// progress is reported every interval and once the line finished, or on each
+// finished item if interval is not positive. report is called one at a time.
+func LineProgress[T any](total uint64, interval time.Duration, report func(Progress)) LineOption[T] {
+	return func(line *Line[T]) {
+		tracker := &progressTracker{total: total, report: report}
+
+		line.hooks = append(line.hooks, func() {
+			tracker.mu.Lock()
+			tracker.clock, tracker.start = line.clock, line.clock.Now()
+			tracker.mu.Unlock()
+
+			if interval > 0 {
+				go tracker.tick(interval, line.done)
+			}
+		})
+
+		line.middleware = append(line.middleware, func(action func(T) error) func(T) error {
+			return func(params T) error {
+				return observe(func(success bool) {
+					tracker.finish(success, interval <= 0)
+				}, func() error {
+					return action(params)
+				})
+			}
+		})
+	}
+}
+
+type progressTracker struct {
+	mu           sync.Mutex
+	clock        Clock
+	start        time.Time
+	total        uint64
+	done, failed uint64
+	report       func(Progress)
+}
+
+func (t *progressTracker) finish(success, report bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if success {
+		t.done++
+	} else {
+		t.failed++
+	}
+	if report {
+		t.report(t.progress())
+	}
+}
+
+func (t *progressTracker) tick(interval time.Duration, done <-chan struct{}) {
+	for {
+		select {
+		case <-t.clock.After(interval):
+		case <-done:
+		}
+
+		t.mu.Lock()
+		t.report(t.progress())
+		t.mu.Unlock()
+
+		select {
+		case <-done:
+			return
+		default:
+		}
+	}
+}
+
+// progress must be called with mu held.
+func (t *progressTracker) progress() Progress {
+	p := Progress{
+		Total:   t.total,
+		Done:    t.done,
+		Failed:  t.failed,
+		Elapsed: t.clock.Now().Sub(t.start),
+	}
+
+	finished := t.done + t.failed
+	if finished == 0 || p.Elapsed <= 0 {
+		return p
+	}
+	p.Throughput = float64(finished) / p.Elapsed.Seconds()
+	if finished < t.total {
+		p.ETA = time.Duration(float64(t.total-finished) / p.Throughput * float64(time.Second))
+	}
+	return p
+}
+
+// TextProgress renders the progress as a single line of a terminal, which
+// is rewritten by each report.
+func TextProgress(w io.Writer) func(Progress) {
+	return func(p Progress) {
+		percent := 100.0
+		if p.Total > 0 {
+			percent = float64(p.Done+p.Failed) / float64(p.Total) * 100
+		}
+		fmt.Fprintf(w, "\r\033[K%5.1f%% %s", percent, p)
+	}
+}
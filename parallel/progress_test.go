@@ -0,0 +1,83 @@
+package parallel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stepClock moves forward by a second on each call of Now.
+type stepClock struct {
+	realClock
+	now time.Time
+}
+
+func (c *stepClock) Now() time.Time {
+	c.now = c.now.Add(time.Second)
+	return c.now
+}
+
+func TestLineProgress(t *testing.T) {
+	var reports []Progress
+	line, supplier := NewLine(1,
+		LineClock[int](&stepClock{}),
+		LineProgress[int](4, 0, func(p Progress) {
+			reports = append(reports, p)
+		}),
+	), make(chan int)
+
+	line.Run(context.Background(), supplier, func(params int) error {
+		if params == 3 {
+			return errors.New("errmsg")
+		}
+		return nil
+	})
+	for i := 0; i < 4; i++ {
+		supplier <- i
+	}
+	close(supplier)
+	assert.EqualError(t, line.Wait(), "errmsg")
+
+	assert.Len(t, reports, 4)
+	// 1 item per second, 3 items left
+	assert.Equal(t, Progress{Total: 4, Done: 1, Elapsed: time.Second, Throughput: 1, ETA: 3 * time.Second}, reports[0])
+	assert.Equal(t, Progress{Total: 4, Done: 3, Failed: 1, Elapsed: 4 * time.Second, Throughput: 1}, reports[3])
+}
+
+func TestLineProgressInterval(t *testing.T) {
+	reports := make(chan Progress, 100)
+	line, supplier := NewLine(2, LineProgress[int](3, time.Millisecond, func(p Progress) {
+		reports <- p
+	})), make(chan int)
+
+	line.Run(context.Background(), supplier, func(int) error {
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	})
+	for i := 0; i < 3; i++ {
+		supplier <- i
+	}
+	close(supplier)
+	assert.NoError(t, line.Wait())
+
+	// the last report is made once the line finished
+	var last Progress
+	for timeout := time.After(time.Second); last.Done < 3; {
+		select {
+		case last = <-reports:
+		case <-timeout:
+			t.Fatal("the final progress is not reported")
+		}
+	}
+	assert.Equal(t, uint64(3), last.Done)
+}
+
+func TestTextProgress(t *testing.T) {
+	buf := &bytes.Buffer{}
+	TextProgress(buf)(Progress{Total: 4, Done: 1, Failed: 1, Elapsed: 2 * time.Second, Throughput: 1, ETA: 2 * time.Second})
+	assert.Equal(t, "\r\033[K 50.0% 1/4 done, 1 failed, 1.0/s, elapsed 2s, ETA 2s", buf.String())
+}
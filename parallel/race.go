@@ -0,0 +1,120 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// FirstSuccess runs the alternatives concurrently and returns the first
+// successful result, the others are canceled through their context.
+// If every alternative fails, the returned error joins all their errors.
+func FirstSuccess[T any](ctx context.Context, fns ...func(context.Context) (T, error)) (T, error) {
+	return race(ctx, fns, 0)
+}
+
+// Hedge calls fn, and starts a duplicate attempt each time the running ones
+// have not succeeded after delay, until max attempts are started. A failed
+// attempt starts the next one at once. The first successful result is
+// returned and the other attempts are canceled.
+// If every attempt fails, the returned error joins all their errors.
+func Hedge[T any](ctx context.Context, fn func(context.Context) (T, error), delay time.Duration, max int) (T, error) {
+	if max < 1 {
+		max = 1
+	}
+	fns := make([]func(context.Context) (T, error), max)
+	for i := range fns {
+		fns[i] = fn
+	}
+	return race(ctx, fns, delay)
+}
+
+type attempt[T any] struct {
+	val T
+	err error
+}
+
+// race starts fns one by one with delay, or all at once when delay is not
+// positive, and returns the first success.
+func race[T any](ctx context.Context, fns []func(context.Context) (T, error), delay time.Duration) (res T, err error) {
+	if len(fns) == 0 {
+		return res, errors.New("no attempts")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// buffered, so the canceled attempts never block
+	results := make(chan attempt[T], len(fns))
+	errs := make([]error, 0, len(fns))
+	started, timer := 0, (*time.Timer)(nil)
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	start := func() {
+		fn := fns[started]
+		started++
+		go func() {
+			var result attempt[T]
+			defer func() {
+				if msg := recover(); msg != nil {
+					result.err = recovered(msg)
+				}
+				results <- result
+			}()
+			result.val, result.err = fn(ctx)
+		}()
+	}
+	next := func() <-chan time.Time {
+		if started == len(fns) {
+			return nil
+		}
+		if timer == nil {
+			timer = time.NewTimer(delay)
+		} else {
+			// drop the fire which is not received
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(delay)
+		}
+		return timer.C
+	}
+
+	if delay <= 0 {
+		for started < len(fns) {
+			start()
+		}
+	} else {
+		start()
+	}
+	wait := next()
+
+	for {
+		select {
+		case result := <-results:
+			if result.err == nil {
+				return result.val, nil
+			}
+			errs = append(errs, result.err)
+			if len(errs) == len(fns) {
+				return res, errors.Join(errs...)
+			}
+			if len(errs) == started {
+				start()
+				wait = next()
+			}
+		case <-wait:
+			start()
+			wait = next()
+		case <-ctx.Done():
+			return res, errors.Join(append(errs, ctx.Err())...)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFirstSuccess(t *testing.T) {
+	canceled := make(chan struct{})
+	val, err := FirstSuccess(context.Background(),
+		func(ctx context.Context) (int, error) {
+			<-ctx.Done()
+			close(canceled)
+			return 0, ctx.Err()
+		},
+		func(context.Context) (int, error) {
+			return 0, errors.New("errmsg")
+		},
+		func(context.Context) (int, error) {
+			return 3, nil
+		},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, val)
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("the slow alternative is not canceled")
+	}
+}
+
+func TestFirstSuccessAllFailed(t *testing.T) {
+	first, second := errors.New("first"), errors.New("second")
+	_, err := FirstSuccess(context.Background(),
+		func(context.Context) (int, error) { return 0, first },
+		func(context.Context) (int, error) { panic(second) },
+	)
+	assert.ErrorIs(t, err, first)
+	assert.ErrorIs(t, err, second)
+
+	_, err = FirstSuccess[int](context.Background())
+	assert.Error(t, err)
+}
+
+func TestHedge(t *testing.T) {
+	calls := int32(0)
+	val, err := Hedge(context.Background(), func(ctx context.Context) (int32, error) {
+		// only the duplicate attempt finishes
+		if n := atomic.AddInt32(&calls, 1); n > 1 {
+			return n, nil
+		}
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}, 10*time.Millisecond, 3)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), val)
+}
+
+func TestHedgeAllFailed(t *testing.T) {
+	calls := int32(0)
+	_, err := Hedge(context.Background(), func(context.Context) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		return 0, errors.New("errmsg")
+	}, time.Hour, 3)
+
+	// failed attempts start the next one without waiting for delay
+	assert.Error(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
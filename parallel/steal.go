@@ -0,0 +1,230 @@
+package parallel
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// StealLine is a Line for the items which spawn child items, e.g. recursive
+// fan-out. Each worker keeps the items it spawned in a local deque and takes
+// the latest one first, an idle worker steals the oldest item of the others.
+//
+// It finishes by itself once all the items and their children are done, or
+// stops at the first panic, error or cancellation the same as Line.
+type StealLine[T any] struct {
+	// line is not embedded, its Run would feed the workers without stealing
+	line Line[T]
+}
+
+func NewStealLine[T any](size uint64, opts ...LineOption[T]) StealLine[T] {
+	return StealLine[T]{line: NewLine(size, opts...)}
+}
+
+// Run starts the workers with roots. The action may call spawn to add child
+// items, spawn must not be called after the action returned.
+func (a *StealLine[T]) Run(ctx context.Context, roots []T, action func(item T, spawn func(T)) error) {
+	ctx = a.line.start(ctx)
+	_, nop := a.line.scheduler.(nopScheduler)
+	run := &stealRun[T]{line: &a.line, deques: make([]deque[T], max(a.line.size, 1)), yield: !nop}
+	run.cond = sync.NewCond(&run.mu)
+
+	run.pending.Store(int64(len(roots)))
+	for i, root := range roots {
+		run.deques[i%len(run.deques)].push(root)
+	}
+	if len(roots) == 0 {
+		run.finish()
+	}
+
+	a.line.wg.Add(int(a.line.size))
+	for i := uint64(0); i < a.line.size; i++ {
+		a.line.scheduler.Enter(i)
+		go run.work(ctx, i, action)
+	}
+
+	// the items which are left are skipped
+	go a.line.finish(func() {
+		a.line.skipped.Add(uint64(run.pending.Load()))
+	})
+}
+
+// Wait until all the items finished, the same as Line.Wait.
+func (a *StealLine[T]) Wait() error {
+	return a.line.Wait()
+}
+
+// WaitReport is the same as Line.WaitReport.
+func (a *StealLine[T]) WaitReport() (Report, error) {
+	return a.line.WaitReport()
+}
+
+// WaitTime is the same as Line.WaitTime.
+func (a *StealLine[T]) WaitTime(timeout time.Duration) error {
+	return a.line.WaitTime(timeout)
+}
+
+// stealRun is the state of a single Run.
+type stealRun[T any] struct {
+	line   *Line[T]
+	deques []deque[T]
+	// pending counts the items which are pushed but not done
+	pending atomic.Int64
+	// idle counts the workers which are looking for items
+	idle atomic.Int32
+	// yield to the scheduler instead of waiting for items, so a custom
+	// scheduler is able to run the other workers
+	yield bool
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	version  uint64
+	finished bool
+}
+
+func (r *stealRun[T]) work(ctx context.Context, worker uint64, action func(T, func(T)) error) {
+	defer r.line.wg.Done()
+	defer r.line.scheduler.Exit(worker)
+
+	spawn := func(item T) {
+		r.pending.Add(1)
+		r.deques[worker].push(item)
+		r.notify()
+	}
+
+	for {
+		r.line.scheduler.Yield(worker)
+		item, ok := r.take(worker)
+		if !ok {
+			return
+		}
+
+		if r.line.stopped(ctx) {
+			r.finish()
+			return
+		}
+
+		r.line.scheduler.Yield(worker)
+		err := r.line.execute(ctx, worker, item, r.line.wrap(func(item T) error {
+			return action(item, spawn)
+		}))
+		if err != nil {
+			r.line.setErr(err)
+		}
+
+		if r.pending.Add(-1) == 0 {
+			r.finish()
+		}
+	}
+}
+
+// take returns the next item of the worker, it waits while the other workers
+// may spawn items, and returns false once the run finished.
+func (r *stealRun[T]) take(worker uint64) (item T, ok bool) {
+	for {
+		if item, ok = r.deques[worker].pop(); ok {
+			return item, true
+		}
+
+		// count as idle before stealing, so the items pushed meanwhile
+		// change the version
+		r.mu.Lock()
+		r.idle.Add(1)
+		version := r.version
+		r.mu.Unlock()
+
+		item, ok = r.steal(worker)
+
+		r.mu.Lock()
+		for !ok && !r.finished && r.version == version && !r.yield {
+			r.cond.Wait()
+		}
+		r.idle.Add(-1)
+		finished := r.finished
+		r.mu.Unlock()
+
+		if ok || finished {
+			return item, ok
+		}
+		if r.yield {
+			r.line.scheduler.Yield(worker)
+		}
+	}
+}
+
+func (r *stealRun[T]) steal(worker uint64) (item T, ok bool) {
+	for i := uint64(1); i < uint64(len(r.deques)); i++ {
+		victim := (worker + i) % uint64(len(r.deques))
+		if item, ok = r.deques[victim].steal(); ok {
+			return item, true
+		}
+	}
+	return
+}
+
+// notify wakes the idle workers for the pushed items.
+func (r *stealRun[T]) notify() {
+	if r.idle.Load() == 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.version++
+	r.cond.Broadcast()
+}
+
+func (r *stealRun[T]) finish() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.finished = true
+	r.cond.Broadcast()
+}
+
+// deque is a double-ended queue, its owner works on the bottom and the
+// thieves take from the top.
+type deque[T any] struct {
+	mu    sync.Mutex
+	items []T
+	head  int
+}
+
+func (d *deque[T]) push(item T) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.items = append(d.items, item)
+}
+
+// pop takes the latest item.
+func (d *deque[T]) pop() (item T, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.head == len(d.items) {
+		return
+	}
+	item = d.items[len(d.items)-1]
+	d.items = d.items[:len(d.items)-1]
+	d.reset()
+	return item, true
+}
+
+// steal takes the oldest item.
+func (d *deque[T]) steal() (item T, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.head == len(d.items) {
+		return
+	}
+	var zero T
+	item, d.items[d.head] = d.items[d.head], zero
+	d.head++
+	d.reset()
+	return item, true
+}
+
+// reset reuses the space once the deque is empty.
+func (d *deque[T]) reset() {
+	if d.head == len(d.items) {
+		d.items, d.head = d.items[:0], 0
+	}
+}
@@ -0,0 +1,74 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// node is an item of a complete binary tree with the depth.
+type node struct {
+	depth int
+}
+
+func TestStealLine(t *testing.T) {
+	line, count := NewStealLine[node](4), int32(0)
+
+	line.Run(context.Background(), []node{{depth: 10}}, func(item node, spawn func(node)) error {
+		atomic.AddInt32(&count, 1)
+		if item.depth > 0 {
+			spawn(node{depth: item.depth - 1})
+			spawn(node{depth: item.depth - 1})
+		}
+		return nil
+	})
+
+	assert.NoError(t, line.Wait())
+	assert.Equal(t, int32(1<<11-1), atomic.LoadInt32(&count))
+}
+
+func TestStealLineEmpty(t *testing.T) {
+	line := NewStealLine[node](2)
+	line.Run(context.Background(), nil, func(node, func(node)) error {
+		return errors.New("should not be called")
+	})
+	assert.NoError(t, line.Wait())
+}
+
+func TestStealLineError(t *testing.T) {
+	line := NewStealLine[node](4)
+	line.Run(context.Background(), []node{{depth: 10}}, func(item node, spawn func(node)) error {
+		if item.depth == 5 {
+			return errors.New("errmsg")
+		}
+		if item.depth > 0 {
+			spawn(node{depth: item.depth - 1})
+		}
+		return nil
+	})
+	assert.EqualError(t, line.Wait(), "errmsg")
+
+	line = NewStealLine[node](4)
+	line.Run(context.Background(), []node{{depth: 1}, {depth: 1}}, func(item node, spawn func(node)) error {
+		if item.depth == 0 {
+			panic("panic msg")
+		}
+		spawn(node{depth: item.depth - 1})
+		return nil
+	})
+	assert.EqualError(t, line.Wait(), "panic msg")
+}
+
+func TestStealLineCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	line := NewStealLine[node](2)
+	line.Run(ctx, []node{{depth: 100}}, func(item node, spawn func(node)) error {
+		cancel()
+		spawn(node{depth: item.depth - 1})
+		return nil
+	})
+	assert.ErrorIs(t, line.Wait(), context.Canceled)
+}